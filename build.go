@@ -1,15 +1,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"html/template"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/russross/blackfriday/v2"
 )
 
 func broadcast(message string) {
@@ -20,45 +19,112 @@ func broadcast(message string) {
 
 func build(reload bool) error {
 	startTime := time.Now()
+	buildTime = startTime
+	site.Title = config.Title
+	site.BaseURL = config.BaseURL
+	site.Author = config.Author
+	site.Language = defaultLanguage()
+	site.Params = config.Params
+	resetMinifyStats()
 	fmt.Println("Building...")
 
+	initializeMarkdown()
+
 	err := initializeSnippets()
 	if err != nil {
-		log.Fatal("Error initializing snippets", err)
+		return fmt.Errorf("initializing snippets: %w", err)
+	}
+
+	err = checkSnippetCycles()
+	if err != nil {
+		return err
+	}
+
+	err = initializeShortcodes()
+	if err != nil {
+		return fmt.Errorf("initializing shortcodes: %w", err)
 	}
 
 	err = initializeLayouts()
 	if err != nil {
-		log.Fatal("Error initializing layouts:", err)
+		return fmt.Errorf("initializing layouts: %w", err)
+	}
+
+	err = initializeData()
+	if err != nil {
+		return fmt.Errorf("initializing data: %w", err)
 	}
 
 	err = initializeDependencies()
 	if err != nil {
-		log.Fatal("Error initializing dependencies:", err)
+		return fmt.Errorf("initializing dependencies: %w", err)
 	}
 
-	_, err = os.Stat(DIST)
-	if err == nil {
-		err = os.RemoveAll(DIST)
-		if err != nil {
-			fmt.Println("Error:", err)
-		}
+	err = initializeCollections()
+	if err != nil {
+		return fmt.Errorf("initializing collections: %w", err)
 	}
 
-	err = os.Mkdir(DIST, 0744)
+	err = initializeTaxonomies()
 	if err != nil {
-		fmt.Println("Error:", err)
+		return fmt.Errorf("initializing taxonomies: %w", err)
 	}
 
-	fmt.Println("Creating directory structure...")
-	err = filepath.Walk(SRC, buildDirs)
+	err = initializeRedirects()
 	if err != nil {
-		fmt.Println("Error:", err)
+		return fmt.Errorf("initializing redirects: %w", err)
 	}
 
-	err = filepath.Walk(SRC, buildPages)
+	err = initializeAssets()
 	if err != nil {
-		fmt.Println("Error:", err)
+		return fmt.Errorf("initializing assets: %w", err)
+	}
+
+	incremental := startBuildCache()
+	if incremental {
+		fmt.Println("Reusing unchanged pages from", CACHE_DIR)
+	}
+
+	// without -keep-going dist is only touched once every page has built
+	holdWrites = !keepGoing
+	if keepGoing {
+		err = prepareOutput(incremental)
+		if err != nil {
+			return err
+		}
+	}
+
+	rendered, skipped, unchanged, failures := buildPages()
+	if len(failures) > 0 {
+		discardHeldWrites()
+	} else if holdWrites {
+		err = prepareOutput(incremental)
+		if err != nil {
+			discardHeldWrites()
+			return err
+		}
+		failures = flushHeldWrites()
+	}
+
+	if len(failures) == 0 || keepGoing {
+		failures = append(failures, buildTaxonomyPages(nil)...)
+		failures = append(failures, buildFeeds()...)
+		failures = append(failures, buildSitemap()...)
+		failures = append(failures, buildRedirects()...)
+		failures = append(failures, buildSyntaxStylesheet()...)
+		failures = append(failures, buildBundles()...)
+		failures = append(failures, buildAssetManifest()...)
+		finishBuildCache(failures)
+	}
+
+	printBuildSummary(rendered, skipped, unchanged)
+	printMinifySummary()
+	if len(failures) > 0 {
+		if !keepGoing {
+			fmt.Println("Nothing was written. Run with -keep-going to write the pages that built.")
+		}
+		sortFailures(failures)
+		return &BuildError{Failures: failures}
 	}
 
 	fmt.Printf("Build complete: %s\n", time.Since(startTime))
@@ -70,19 +136,40 @@ func build(reload bool) error {
 	return nil
 }
 
+// prepareOutput empties dist for a full build and creates its directories.
+func prepareOutput(incremental bool) error {
+	if !incremental {
+		err := os.RemoveAll(config.Output)
+		if err != nil {
+			return err
+		}
+
+		err = os.Mkdir(config.Output, 0744)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("Creating directory structure...")
+	err := filepath.Walk(config.Source, buildDirs)
+	if err != nil {
+		return fmt.Errorf("creating directories: %w", err)
+	}
+	return nil
+}
+
 func buildDirs(srcPath string, info os.FileInfo, err error) error {
-	distPath := replaceAWithB(srcPath, "src/", DIST+"/")
-	distPath = replaceAWithB(distPath, "src", DIST)
-	distPath = replaceAWithB(distPath, "/pages", "")
+	if err != nil {
+		return err
+	}
 
-	if info.IsDir() && !strings.HasPrefix(srcPath, SRC+"/snippets") && !strings.HasPrefix(srcPath, SRC+"/layouts") {
+	distPath := destPathFor(srcPath)
+
+	if info.IsDir() && !inSrcDir(srcPath, "snippets") && !inSrcDir(srcPath, "shortcodes") && !inSrcDir(srcPath, "layouts") && !inSrcDir(srcPath, "data") {
 		fmt.Printf("  %s -> %s\n", srcPath, distPath)
 		_, err := os.Stat(distPath)
 		if err != nil {
-			err = os.Mkdir(distPath, 0755)
-			if err != nil {
-				fmt.Println("Error:", err)
-			}
+			return os.Mkdir(distPath, 0755)
 		}
 	} else if info.IsDir() {
 		fmt.Println("  Skipping", srcPath)
@@ -91,67 +178,189 @@ func buildDirs(srcPath string, info os.FileInfo, err error) error {
 	return nil
 }
 
-func buildPages(srcPath string, info os.FileInfo, err error) error {
+// buildPages finds the files to build and renders them with the worker
+// pool. It returns how many files it rendered, skipped and left unchanged,
+// and the failures.
+func buildPages() (int, int, int, []PageError) {
+	var srcPaths []string
+	var failures []PageError
+	unchanged := 0
+
+	err := filepath.Walk(config.Source, func(srcPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			failures = append(failures, PageError{Path: srcPath, Err: err})
+			return nil
+		}
+
+		if inSrcDir(srcPath, "snippets") || inSrcDir(srcPath, "shortcodes") || inSrcDir(srcPath, "layouts") || inSrcDir(srcPath, "data") || filepath.Clean(srcPath) == redirectsPath() {
+			fmt.Println("  Skipping", srcPath)
+			return nil
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		if upToDate(srcPath) {
+			fmt.Println("  Unchanged", srcPath)
+			unchanged++
+			return nil
+		}
+
+		srcPaths = append(srcPaths, srcPath)
+		return nil
+	})
+	if err != nil {
+		failures = append(failures, PageError{Path: config.Source, Err: err})
+	}
+
+	rendered, skipped, renderFailures := renderPages(srcPaths)
+	return rendered, skipped, unchanged, append(failures, renderFailures...)
+}
+
+// destPathFor maps src/pages/blog/post.md to dist/blog/post.html and
+// src/assets/css/styles.css to dist/assets/css/styles.css.
+func destPathFor(srcPath string) string {
+	rel, err := filepath.Rel(config.Source, srcPath)
 	if err != nil {
-		fmt.Println("Error:", err)
+		rel = srcPath
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "pages" {
+		rel = "."
 	}
+	rel = strings.TrimPrefix(rel, "pages/")
 
-	if strings.HasPrefix(srcPath, SRC+"/snippets/") || strings.HasPrefix(srcPath, SRC+"/layouts/") {
-		fmt.Println("  Skipping", srcPath)
-		return nil
+	distPath := filepath.Join(config.Output, filepath.FromSlash(rel))
+	if strings.HasSuffix(distPath, ".md") {
+		distPath = strings.TrimSuffix(distPath, ".md") + ".html"
+	}
+	return distPath
+}
+
+// buildPage builds the file at srcPath and reports whether it was built,
+// which drafts and scheduled pages aren't.
+func buildPage(srcPath string) (bool, error) {
+	data, err := os.ReadFile(srcPath)
+	if err != nil {
+		return false, err
 	}
 
-	var wg sync.WaitGroup
+	destPath := destPathFor(srcPath)
 
-	if !info.IsDir() {
-		wg.Add(1)
-		go buildPage(srcPath, &wg)
+	if !strings.HasSuffix(srcPath, ".md") && !strings.HasSuffix(srcPath, ".html") {
+		// assets files: css, js, etc
+		if !inSrcDir(srcPath, "assets") {
+			return true, writePage(srcPath, destPath, data)
+		}
+		if strings.HasSuffix(srcPath, ".css") {
+			data = rewriteStylesheet(assetURL(srcPath), data)
+		}
+		return true, writeAsset(srcPath, assetURL(srcPath), data)
 	}
 
-	wg.Wait()
+	frontMatter, data, err := parseFrontMatter(data)
+	if err != nil {
+		return false, err
+	}
+	destPath = pageDestPath(srcPath, frontMatter)
 
-	return nil
+	if !shouldBuild(frontMatter) {
+		fmt.Printf("  Skipping %s page %s\n", pageStatus(frontMatter), srcPath)
+		return false, nil
+	}
+
+	err = checkPermalinkDate(srcPath, frontMatter)
+	if err != nil {
+		return false, err
+	}
+
+	page := newPage(srcPath, frontMatter, destPath)
+
+	if frontMatter.Paginate != "" {
+		pages := paginate(page, destPath)
+		var errs []error
+		for _, paginated := range pages {
+			rendered, err := renderPage(srcPath, data, paginated.Page)
+			errs = append(errs, err, writePage(srcPath, paginated.DestPath, rendered))
+		}
+		removeStalePagination(page, len(pages))
+		return true, errors.Join(errs...)
+	}
+
+	rendered, renderErr := renderPage(srcPath, data, page)
+	return true, errors.Join(renderErr, writePage(srcPath, destPath, rendered))
 }
 
-func buildPage(srcPath string, wg *sync.WaitGroup) {
+// rebuildPage is buildPage for the file watcher, which reports errors and
+// carries on.
+func rebuildPage(srcPath string, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	distPath := replaceAWithB(srcPath, "src/", "dist/")
-	distPath = replaceAWithB(distPath, "src", "dist")
-	distPath = replaceAWithB(distPath, "/pages", "")
+	_, err := buildPage(srcPath)
+	reportFailures(failure(srcPath, err))
+}
 
-	data, err := os.ReadFile(srcPath)
-	if err != nil {
-		fmt.Println("Error:", err)
+// renderPage renders a page into its layouts. When something fails it still
+// returns as much of the page as it could along with the error.
+func renderPage(srcPath string, data []byte, page Page) ([]byte, error) {
+	content, contentErr := renderContent(srcPath, data, page)
+	content, toc, tocErr := tableOfContents(content, page.FrontMatter)
+	page.TOC = toc
+	page.TableOfContents = tocHTML(toc)
+	rendered, layoutErr := wrapHtmlInLayout(content, page)
+	rendered = addHreflangLinks(rendered, page)
+	rendered = rewriteAssetReferences(rendered)
+	if showStatusBanner {
+		rendered = addStatusBanner(rendered, page.FrontMatter)
 	}
+	return rendered, errors.Join(contentErr, tocErr, layoutErr)
+}
 
-	var destPath string
-	var wrappedData []byte
-
+// renderContent renders a page's body without its layout.
+func renderContent(srcPath string, data []byte, page Page) ([]byte, error) {
+	var renderErr error
 	switch {
-	case strings.HasSuffix(distPath, ".md"):
+	case strings.HasSuffix(srcPath, ".md"):
 		// parse markdown to html
-		data = blackfriday.Run(data)
-		destPath = replaceAWithB(distPath, ".md", ".html")
-	case strings.HasSuffix(distPath, ".html"):
-		destPath = distPath
-	default:
-		// assets files: css, js, etc
-		wrappedData = data
-		destPath = distPath
+		rendered, err := renderMarkdownWithShortcodes(data)
+		if err != nil {
+			renderErr = err
+		}
+		data = rendered
+	case strings.HasSuffix(srcPath, ".html") && isTemplate(page.FrontMatter):
+		// html pages with front matter are templates too, so they can list collections
+		rendered, err := renderTemplate(srcPath, string(data), page)
+		if err != nil {
+			renderErr = err
+		} else {
+			data = []byte(rendered)
+		}
 	}
 
-	if strings.HasSuffix(destPath, ".html") {
-		dataWithSnippets := processSnippets(data)
-		wrappedData = wrapHtmlInLayout(dataWithSnippets)
+	data, snippetErr := processSnippets(data)
+	data, highlightErr := highlightHTML(data)
+	return data, errors.Join(renderErr, snippetErr, highlightErr)
+}
+
+func writePage(srcPath string, destPath string, data []byte) error {
+	if holdWrites {
+		heldWritesMutex.Lock()
+		heldWrites = append(heldWrites, heldWrite{SrcPath: srcPath, DestPath: destPath, Data: data})
+		heldWritesMutex.Unlock()
+		return nil
 	}
 
-	fmt.Printf("  %s -> %s\n", srcPath, destPath)
+	data, savings, minifyErr := minifyOutput(destPath, data)
+	fmt.Printf("  %s -> %s%s\n", srcPath, destPath, savings)
+	recordOutput(srcPath, destPath)
 
-	err = os.WriteFile(destPath, wrappedData, 0644)
+	err := os.MkdirAll(filepath.Dir(destPath), 0755)
 	if err != nil {
-		fmt.Println("Error:", err)
+		return err
 	}
+
+	return errors.Join(minifyErr, os.WriteFile(destPath, data, 0644))
 }
 
 func initializeSnippets() error {
@@ -159,17 +368,21 @@ func initializeSnippets() error {
 
 	snippets = []Snippet{}
 
-	if _, err := os.Stat(SRC + "/snippets"); os.IsNotExist(err) {
+	if _, err := os.Stat(filepath.Join(config.Source, "snippets")); os.IsNotExist(err) {
 		fmt.Println("Skipping snippets initialization. Directory does not exist.")
 		return nil
 	}
 
-	err := filepath.Walk(SRC+"/snippets", func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(filepath.Join(config.Source, "snippets"), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 		var snippet Snippet
 		if !info.IsDir() {
 			base := filepath.Base(path)
 			snippet.Name = strings.TrimSuffix(base, filepath.Ext(base))
 			snippet.Path = path
+			snippet.Pattern = snippetPattern(snippet.Name)
 			snippets = append(snippets, snippet)
 		}
 		return nil
@@ -187,57 +400,113 @@ func initializeDependencies() error {
 
 	dependencies = make(map[string][]string)
 
-	filepath.Walk(SRC, func(path string, info os.FileInfo, err error) error {
+	return filepath.Walk(config.Source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 		if info.IsDir() {
 			return nil
 		}
-		if strings.Contains(path, "src/snippets/") || strings.Contains(path, "src/layouts/") {
-			// TODO: refactor this to consider nested snippets
-			// TODO: refactor this to consider snippets in a layout. Would that mean cascading dependencies?
+		if inSrcDir(path, "snippets") || inSrcDir(path, "shortcodes") || inSrcDir(path, "layouts") || inSrcDir(path, "data") {
 			return nil
 		}
 
 		file := filepath.Base(path)
 		ext := filepath.Ext(file)
 
+		if ext == ".css" && fingerprinting() {
+			addDependency(ASSETS_DEPENDENCY, path)
+		}
+
 		if ext == ".html" || ext == ".md" {
 			content, err := os.ReadFile(path)
 			if err != nil {
 				return err
 			}
 
-			foundLayout := false
+			// errors in the page are reported when it is built
+			frontMatter, body, err := parseFrontMatter(content)
+			if err != nil {
+				return nil
+			}
+
+			layout, _, _ := pageLayout(string(body), frontMatter)
+			chain, _ := layoutChain(layout)
+			addDependency(layout.Path, path)
+			sources := []string{string(body)}
+			for _, step := range chain {
+				addDependency(step.Layout.Path, path)
+				sources = append(sources, step.Body)
+			}
+
+			if ext == ".md" {
+				for _, shortcode := range shortcodesUsedBy(body) {
+					addDependency(shortcode.Path, path)
 
-			for _, layout := range layouts {
-				if strings.HasPrefix(string(content), "<"+layout.Name+"Layout>") && (strings.HasSuffix(string(content), "</"+layout.Name+"Layout>") || strings.HasSuffix(string(content), "</"+layout.Name+"Layout>\n")) {
-					foundLayout = true
-					if !sliceContains(path, dependencies[layout.Path]) {
-						dependencies[layout.Path] = append(dependencies[layout.Path], path)
+					shortcodeContent, err := os.ReadFile(shortcode.Path)
+					if err != nil {
+						return err
 					}
+					sources = append(sources, string(shortcodeContent))
 				}
 			}
 
-			if foundLayout == false {
-				if !sliceContains(path, dependencies[DEFAULT_LAYOUT]) {
-					dependencies[DEFAULT_LAYOUT] = append(dependencies[DEFAULT_LAYOUT], path)
+			language, _ := languageSourcePath(path)
+			if frontMatter.Paginate != "" {
+				for _, dir := range collectionDirs(frontMatter.Paginate, language) {
+					addDependency(dir, path)
 				}
 			}
 
-			for _, snippet := range snippets {
-				content, err := os.ReadFile(path)
-				if err != nil {
-					return err
+			if multilingual() {
+				addDependency(TRANSLATIONS_DEPENDENCY_PREFIX+translationKey(path), path)
+			}
+
+			for _, source := range sources {
+				used, _ := snippetsUsedBy(source)
+				for _, snippet := range used {
+					addDependency(snippet.Path, path)
+
+					snippetContent, err := os.ReadFile(snippet.Path)
+					if err != nil {
+						return err
+					}
+					sources = append(sources, string(snippetContent))
+				}
+			}
+
+			for _, source := range sources {
+				if strings.Contains(source, ".Taxonomies") {
+					addDependency(TAXONOMIES_DEPENDENCY, path)
+				}
+
+				if strings.Contains(source, "/assets/") && fingerprinting() {
+					addDependency(ASSETS_DEPENDENCY, path)
+				}
+
+				if strings.Contains(source, ".Site.Pages") {
+					addDependency(filepath.Join(config.Source, "pages"), path)
+				}
+
+				for _, name := range collectionsUsedBy(source) {
+					for _, dir := range collectionDirs(name, language) {
+						addDependency(dir, path)
+					}
+				}
+
+				for _, file := range dataUsedBy(source) {
+					addDependency(file.Path, path)
 				}
-				if strings.Contains(string(content), "<"+snippet.Name+"></"+snippet.Name+">") {
-					if !sliceContains(path, dependencies[snippet.Path]) {
-						dependencies[snippet.Path] = append(dependencies[snippet.Path], path)
+
+				if strings.Contains(source, ".Strings") {
+					for _, file := range stringsDataFiles(language) {
+						addDependency(file.Path, path)
 					}
 				}
 			}
 		}
 		return nil
 	})
-	return nil
 }
 
 func initializeLayouts() error {
@@ -245,10 +514,13 @@ func initializeLayouts() error {
 
 	layouts = []Layout{}
 
-	if _, err := os.Stat(SRC + "/layouts"); os.IsNotExist(err) {
-		return fmt.Errorf("%v/layouts not found", SRC)
+	if _, err := os.Stat(filepath.Join(config.Source, "layouts")); os.IsNotExist(err) {
+		return fmt.Errorf("%v/layouts not found", config.Source)
 	}
-	err := filepath.Walk(SRC+"/layouts", func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(filepath.Join(config.Source, "layouts"), func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 		var layout Layout
 		if !info.IsDir() {
 			base := filepath.Base(path)
@@ -266,63 +538,33 @@ func initializeLayouts() error {
 	return nil
 }
 
-func processSnippets(data []byte) []byte {
-	fmt.Println("Processing snippets...")
-	content := string(data)
-	for _, snippet := range snippets {
-		snippetString := "<" + snippet.Name + "></" + snippet.Name + ">"
-		if strings.Contains(content, snippetString) {
-			snippetContent, err := os.ReadFile(snippet.Path)
-			if err != nil {
-				fmt.Println(err)
-			}
+func wrapHtmlInLayout(data []byte, page Page) ([]byte, error) {
+	fmt.Println("Wrapping in layout...")
 
-			content = replaceAWithB(content, snippetString, string(snippetContent))
-		}
-	}
-	return []byte(content)
-}
+	layout, content, layoutErr := pageLayout(string(data), page.FrontMatter)
 
-func wrapHtmlInLayout(data []byte) []byte {
-	fmt.Println("Wrapping in layout...")
-	defaultLayoutPath := SRC + "/layouts/Default.html"
-
-	var wrappedData string
-	var unwrappedData string
-	var rawLayout []byte
-	var layout string
-	var err error
-	var openTag string
-	var closeTag string
-
-	for _, layout := range layouts {
-		openTag = "<" + layout.Name + "Layout>"
-		closeTag = "</" + layout.Name + "Layout>"
-		if strings.HasPrefix(string(data), openTag) && strings.Contains(string(data), closeTag) {
-			layoutPath := layout.Path
-			_, err := os.Stat(layoutPath)
-			if err == nil {
-				rawLayout, err = os.ReadFile(layoutPath)
-				if err != nil {
-					fmt.Println("Error:", err)
-				}
-			}
-			unwrappedData = replaceAWithB(string(data), openTag, "")
-			unwrappedData = replaceAWithB(unwrappedData, closeTag, "")
-		}
+	chain, err := layoutChain(layout)
+	if err != nil {
+		return []byte(content), errors.Join(layoutErr, err)
 	}
 
-	if len(rawLayout) == 0 {
-		unwrappedData = replaceAWithB(string(data), "<DefaultLayout>", "")
-		unwrappedData = replaceAWithB(unwrappedData, "</DefaultLayout>", "")
-		rawLayout, err = os.ReadFile(defaultLayoutPath)
+	errs := []error{layoutErr}
+	for _, step := range chain {
+		page.Content = template.HTML(content)
+		expanded, err := processSnippets([]byte(step.Body))
+		errs = append(errs, err)
+		body := string(expanded)
+		if !step.Template {
+			content = replaceAWithB(body, "__CONTENT__", content)
+			continue
+		}
+		rendered, err := renderTemplate(step.Layout.Path, body, page)
 		if err != nil {
-			fmt.Println("Error:", err)
+			errs = append(errs, err)
+			rendered = replaceAWithB(body, "__CONTENT__", content)
 		}
+		content = rendered
 	}
 
-	layout = string(rawLayout)
-	wrappedData = replaceAWithB(layout, "__CONTENT__", unwrappedData)
-
-	return []byte(wrappedData)
+	return []byte(content), errors.Join(errs...)
 }
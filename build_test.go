@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestSite writes files, relative to the project directory, into a
+// temporary directory and changes into it with the default config.
+func newTestSite(t testing.TB, files map[string]string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		writeTestFile(t, filepath.Join(dir, name), content)
+	}
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+
+	config = defaultConfig()
+	cleanBuild = false
+	useBuildCache = true
+	keepGoing = false
+}
+
+func writeTestFile(t testing.TB, path string, content string) {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readOutput returns every file in the output directory by its path.
+func readOutput(t testing.TB) map[string]string {
+	t.Helper()
+
+	output := make(map[string]string)
+	err := filepath.WalkDir(config.Output, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		output[path] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output
+}
+
+func TestIncrementalBuildMatchesCleanBuild(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"src/layouts/Default.html": "<html><body><aside>{{range .Site.Collections.blog}}<a href=\"{{.Path}}\">{{.Title}}</a>{{end}}</aside>__CONTENT__</body></html>",
+		"src/pages/about.html":     "<p>About</p>",
+		"src/pages/blog/first.md":  "---\ntitle: First\ndate: 2024-01-01\n---\nFirst post\n",
+		"src/pages/blog/second.md": "---\ntitle: Second\ndate: 2024-02-01\n---\nSecond post\n",
+		"src/pages/blog/third.md":  "---\ntitle: Third\ndate: 2024-03-01\n---\nThird post\n",
+	})
+
+	err := build(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFile(t, "src/pages/blog/fourth.md", "---\ntitle: Fourth\ndate: 2024-04-01\n---\nFourth post\n")
+	err = build(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	incremental := readOutput(t)
+
+	cleanBuild = true
+	err = build(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clean := readOutput(t)
+
+	for path, content := range clean {
+		if incremental[path] != content {
+			t.Errorf("%s after an incremental build:\n%s\nafter a clean build:\n%s", path, incremental[path], content)
+		}
+	}
+	for path := range incremental {
+		if _, found := clean[path]; !found {
+			t.Errorf("%s is left over from an incremental build", path)
+		}
+	}
+}
+
+func TestFailedBuildReportsEveryPage(t *testing.T) {
+	files := map[string]string{
+		"src/layouts/Default.html": "<html><body>__CONTENT__</body></html>",
+		"src/pages/about.html":     "<p>About</p>",
+		"src/pages/broken.html":    "---\ntitle: Broken\n---\n{{.Missing}",
+		"src/pages/unclosed.html":  "---\ntitle: Unclosed\n---\n{{range .Tags}}",
+	}
+
+	for _, keepGoingFlag := range []bool{false, true} {
+		newTestSite(t, files)
+		keepGoing = keepGoingFlag
+
+		err := build(false)
+		var buildErr *BuildError
+		if !errors.As(err, &buildErr) {
+			t.Fatalf("keepGoing %v: got %v, want a BuildError", keepGoingFlag, err)
+		}
+		if len(buildErr.Failures) != 2 {
+			t.Errorf("keepGoing %v: got %d failures, want 2:\n%v", keepGoingFlag, len(buildErr.Failures), err)
+		}
+
+		_, statErr := os.Stat(filepath.Join(config.Output, "about.html"))
+		if keepGoingFlag && statErr != nil {
+			t.Errorf("keepGoing: about.html wasn't written: %v", statErr)
+		}
+		if !keepGoingFlag && statErr == nil {
+			t.Errorf("about.html was written although the build failed")
+		}
+	}
+	keepGoing = false
+}
+
+func TestUnknownLayoutFailsThePage(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"src/layouts/Default.html": "<html><body>__CONTENT__</body></html>",
+		"src/pages/about.md":       "---\nlayout: Missing\n---\nAbout\n",
+	})
+
+	err := build(false)
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) || len(buildErr.Failures) != 1 {
+		t.Fatalf("got %v, want about.md to fail", err)
+	}
+	if !strings.Contains(err.Error(), "layout not found: Missing") {
+		t.Errorf("got %v, want it to name the missing layout", err)
+	}
+}
+
+func TestLayoutWithoutTemplate(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"src/layouts/Default.html": "---\ntemplate: false\n---\n<html><body><div id=\"app\">{{ msg }}</div>__CONTENT__</body></html>",
+		"src/pages/about.html":     "---\ntitle: About\n---\n<p>{{.Title}}</p>",
+	})
+
+	err := build(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(config.Output, "about.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<html><body><div id=\"app\">{{ msg }}</div><p>About</p></body></html>"
+	if strings.TrimSpace(string(data)) != want {
+		t.Errorf("got\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestFailedBuildKeepsStalePagination(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"src/layouts/Default.html":  "<html><body>__CONTENT__</body></html>",
+		"src/pages/blog/index.html": "---\npaginate: blog\nperPage: 1\n---\n{{range .Paginator.Items}}<p>{{.Title}}</p>{{end}}",
+		"src/pages/blog/first.md":   "---\ntitle: First\ndate: 2024-01-01\n---\nFirst\n",
+		"src/pages/blog/second.md":  "---\ntitle: Second\ndate: 2024-02-01\n---\nSecond\n",
+	})
+	secondPage := filepath.Join(config.Output, "blog", "page", "2")
+
+	err := build(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(secondPage); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFile(t, "src/pages/blog/index.html", "---\npaginate: blog\nperPage: 10\n---\n{{range .Paginator.Items}}<p>{{.Title}}</p>{{end}}")
+	writeTestFile(t, "src/pages/broken.html", "---\ntitle: Broken\n---\n{{.Missing}")
+	err = build(false)
+	if err == nil {
+		t.Fatal("the build didn't fail")
+	}
+	if _, err := os.Stat(secondPage); err != nil {
+		t.Errorf("a failed build deleted %s: %v", secondPage, err)
+	}
+
+	os.Remove("src/pages/broken.html")
+	err = build(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(secondPage); err == nil {
+		t.Errorf("%s is left over", secondPage)
+	}
+}
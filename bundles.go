@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Bundles join several assets into one file so a page needs fewer requests.
+// Each bundle is listed in sssg.yaml with its files, relative to src/assets,
+// in the order they are joined:
+//
+// bundles:
+//   css/main.css: [css/pico.min.css, css/styles.css]
+//   js/app.js: [js/vendor.js, js/app.js]
+//
+// and is written to dist/assets, so link it as /assets/css/main.css. Like
+// any other asset a bundle is minified and fingerprinted when those are on.
+
+var bundleExtensions = []string{".css", ".js"}
+
+func validateBundles(cfg Config) error {
+	for name, files := range cfg.Bundles {
+		if !sliceContains(path.Ext(name), bundleExtensions) {
+			return fmt.Errorf("bundles: %q should be a .css or .js file", name)
+		}
+		if !validAssetPath(name) {
+			return fmt.Errorf("bundles: %q must be a path inside the assets directory", name)
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("bundles.%s: list the files to join", name)
+		}
+		for _, file := range files {
+			if !validAssetPath(file) {
+				return fmt.Errorf("bundles.%s: %q must be a path inside the assets directory", name, file)
+			}
+		}
+	}
+	return nil
+}
+
+func validAssetPath(name string) bool {
+	clean := path.Clean(name)
+	return name != "" && !path.IsAbs(clean) && clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+// bundleURL turns the bundle css/main.css into /assets/css/main.css.
+func bundleURL(name string) string {
+	return "/assets/" + path.Clean(name)
+}
+
+// bundleContent joins the files of a bundle. Stylesheets are joined with a
+// line break and scripts with a semicolon too, in case one doesn't end its
+// last statement.
+func bundleContent(name string) ([]byte, error) {
+	separator := "\n"
+	if path.Ext(name) == ".js" {
+		separator = ";\n"
+	}
+
+	var content bytes.Buffer
+	for i, file := range config.Bundles[name] {
+		data, err := os.ReadFile(filepath.Join(config.Source, "assets", filepath.FromSlash(file)))
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			content.WriteString(separator)
+		}
+		content.Write(data)
+	}
+	return content.Bytes(), nil
+}
+
+// inBundle reports whether the asset at srcPath is part of a bundle.
+func inBundle(srcPath string) bool {
+	for _, files := range config.Bundles {
+		for _, file := range files {
+			if filepath.Clean(srcPath) == filepath.Join(config.Source, "assets", filepath.FromSlash(file)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func buildBundles() []PageError {
+	var failures []PageError
+	for name := range config.Bundles {
+		destPath := assetOutputPath(bundleURL(name))
+
+		content, err := bundleContent(name)
+		if err != nil {
+			failures = append(failures, failure(destPath, err)...)
+			continue
+		}
+		if path.Ext(name) == ".css" {
+			content = rewriteStylesheet(bundleURL(name), content)
+		}
+		failures = append(failures, failure(destPath, writeAsset("(bundle)", bundleURL(name), content))...)
+	}
+	return failures
+}
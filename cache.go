@@ -0,0 +1,312 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// sssg build keeps a cache in .sssg-cache/ so that it only renders the pages
+// whose inputs changed since the last build. For every source file the cache
+// records a hash of its inputs and the files it was built to. The inputs are
+// the file itself, its layout chain, the snippets and data files it uses, the
+// collections and taxonomies it lists, and the config. Outputs whose source
+// is gone are deleted from dist.
+//
+// sssg -build -clean ignores the cache and rebuilds everything. sssg dev
+// always does a full build and removes the cache, because it builds drafts
+// and adds status banners.
+
+const CACHE_DIR = ".sssg-cache"
+const CACHE_VERSION = 1
+
+type BuildCache struct {
+	Version int                   `json:"version"`
+	Entries map[string]CacheEntry `json:"entries"`
+}
+
+type CacheEntry struct {
+	Hash    string   `json:"hash"`
+	Outputs []string `json:"outputs"`
+}
+
+var cleanBuild bool
+var useBuildCache = true
+
+var previousCache BuildCache
+var currentCache BuildCache
+var builtOutputs = make(map[string][]string)
+var builtOutputsMutex sync.Mutex
+var inputsByPage map[string][]string
+var inputHashes map[string]string
+var configHash string
+
+func cacheManifestPath() string {
+	return filepath.Join(CACHE_DIR, "manifest.json")
+}
+
+// startBuildCache reads the previous cache and reports whether the build can
+// be incremental. It is called once dependencies, collections and
+// taxonomies are initialized.
+func startBuildCache() bool {
+	currentCache = BuildCache{Version: CACHE_VERSION, Entries: make(map[string]CacheEntry)}
+	previousCache = BuildCache{}
+	builtOutputs = make(map[string][]string)
+	inputHashes = make(map[string]string)
+
+	if !useBuildCache {
+		removeBuildCache()
+		return false
+	}
+
+	inputsByPage = make(map[string][]string)
+	for input, dependents := range dependencies {
+		for _, dependent := range dependents {
+			inputsByPage[dependent] = append(inputsByPage[dependent], input)
+		}
+	}
+	for page := range inputsByPage {
+		sort.Strings(inputsByPage[page])
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "version %d\n", CACHE_VERSION)
+	fmt.Fprintf(h, "drafts %t future %t expired %t minify %t\n", includeDrafts, includeFuture, includeExpired, minifying())
+	for _, name := range CONFIG_FILES {
+		writeFileHash(h, name)
+	}
+	configHash = hex.EncodeToString(h.Sum(nil))
+
+	if cleanBuild {
+		fmt.Println("Clean build, ignoring the build cache...")
+		return false
+	}
+
+	data, err := os.ReadFile(cacheManifestPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Println("Error:", err)
+		}
+		return false
+	}
+
+	err = json.Unmarshal(data, &previousCache)
+	if err != nil {
+		fmt.Println("Error: ignoring the build cache:", err)
+		return false
+	}
+	if previousCache.Version != CACHE_VERSION {
+		return false
+	}
+
+	_, err = os.Stat(config.Output)
+	return err == nil
+}
+
+// upToDate reports whether srcPath has the same inputs as in the last build
+// and its outputs are still there. Its outputs are then kept as they are.
+func upToDate(srcPath string) bool {
+	if !useBuildCache {
+		return false
+	}
+
+	sum := sourceHash(srcPath)
+	currentCache.Entries[srcPath] = CacheEntry{Hash: sum}
+
+	previous, found := previousCache.Entries[srcPath]
+	if !found || previous.Hash != sum {
+		return false
+	}
+	for _, output := range previous.Outputs {
+		if _, err := os.Stat(output); err != nil {
+			return false
+		}
+	}
+
+	for _, output := range previous.Outputs {
+		recordOutput(srcPath, output)
+	}
+	return true
+}
+
+// finishBuildCache deletes the outputs of the last build that this build
+// didn't write or keep, and saves the cache for the next build. Failed files
+// are saved without a hash so the next build tries them again.
+func finishBuildCache(failures []PageError) {
+	if !useBuildCache {
+		return
+	}
+
+	for _, failure := range failures {
+		entry := currentCache.Entries[failure.Path]
+		entry.Hash = ""
+		currentCache.Entries[failure.Path] = entry
+	}
+
+	written := make(map[string]bool)
+	for src, outputs := range builtOutputs {
+		entry := currentCache.Entries[src]
+		entry.Outputs = outputs
+		currentCache.Entries[src] = entry
+		for _, output := range outputs {
+			written[output] = true
+		}
+	}
+
+	for _, entry := range previousCache.Entries {
+		for _, output := range entry.Outputs {
+			if !written[output] {
+				removeStaleOutput(output)
+			}
+		}
+	}
+
+	err := os.MkdirAll(CACHE_DIR, 0755)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	data, err := json.MarshalIndent(currentCache, "", "  ")
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	err = os.WriteFile(cacheManifestPath(), data, 0644)
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+}
+
+func removeBuildCache() {
+	err := os.RemoveAll(CACHE_DIR)
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+}
+
+func recordOutput(srcPath string, destPath string) {
+	if !useBuildCache {
+		return
+	}
+
+	builtOutputsMutex.Lock()
+	defer builtOutputsMutex.Unlock()
+
+	if !sliceContains(destPath, builtOutputs[srcPath]) {
+		builtOutputs[srcPath] = append(builtOutputs[srcPath], destPath)
+	}
+}
+
+// removeStaleOutput deletes an output along with the directories above it
+// that are left empty.
+func removeStaleOutput(destPath string) {
+	fmt.Println("Deleting from dist:", destPath)
+
+	err := os.Remove(destPath)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Println("Error deleting:", destPath, err)
+		return
+	}
+
+	output := filepath.Clean(config.Output)
+	for dir := filepath.Dir(destPath); strings.HasPrefix(dir, output+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+}
+
+func sourceHash(srcPath string) string {
+	h := sha256.New()
+	fmt.Fprintln(h, configHash)
+	writeFileHash(h, srcPath)
+
+	if strings.HasSuffix(srcPath, ".md") || strings.HasSuffix(srcPath, ".html") {
+		frontMatter, _, _ := readFrontMatter(srcPath)
+		fmt.Fprintf(h, "status %q\n", pageStatus(frontMatter))
+	}
+
+	for _, input := range inputsByPage[srcPath] {
+		fmt.Fprintf(h, "%s %s\n", input, inputHash(input))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// inputHash hashes a layout, snippet or data file, a collection directory,
+// the taxonomies, a page's translations or the fingerprinted asset names.
+func inputHash(input string) string {
+	if sum, found := inputHashes[input]; found {
+		return sum
+	}
+
+	h := sha256.New()
+	if input == TAXONOMIES_DEPENDENCY {
+		writeTaxonomiesHash(h)
+	} else if input == ASSETS_DEPENDENCY {
+		writeAssetsHash(h)
+	} else if key, found := strings.CutPrefix(input, TRANSLATIONS_DEPENDENCY_PREFIX); found {
+		writeTranslationsHash(h, key)
+	} else if info, err := os.Stat(input); err != nil {
+		fmt.Fprintln(h, "missing")
+	} else if info.IsDir() {
+		writeDirHash(h, input)
+	} else {
+		writeFileHash(h, input)
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	inputHashes[input] = sum
+	return sum
+}
+
+func writeFileHash(h hash.Hash, path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(h, "%s missing\n", path)
+		return
+	}
+	defer file.Close()
+
+	fmt.Fprintf(h, "%s\n", path)
+	io.Copy(h, file)
+	fmt.Fprintln(h)
+}
+
+// writeDirHash covers every file in a collection and which of its pages are
+// published, since that changes over time without the files changing.
+func writeDirHash(h hash.Hash, dir string) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			writeFileHash(h, path)
+		}
+		return nil
+	})
+
+	for _, page := range site.Pages {
+		if strings.HasPrefix(page.SourcePath, dir+string(filepath.Separator)) {
+			fmt.Fprintf(h, "published %s\n", page.SourcePath)
+		}
+	}
+}
+
+func writeTaxonomiesHash(h hash.Hash) {
+	for _, taxonomy := range taxonomyNames {
+		for _, term := range site.Taxonomies[taxonomy] {
+			fmt.Fprintf(h, "%s %s %s\n", taxonomy, term.Slug, term.Name)
+			for _, page := range term.Pages {
+				fmt.Fprintf(h, "  %s %s %s\n", page.SourcePath, page.Path, page.Title)
+			}
+		}
+	}
+}
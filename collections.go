@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Every directory under src/pages is a collection named after its path, so
+// src/pages/blog/*.md is the "blog" collection. Collections are gathered
+// before any page is built, sorted newest first, and are available to
+// templates as .Site.Collections:
+//
+// {{range .Site.Collections.blog}}
+//   <a href="{{.Path}}">{{.Title}}</a> {{.Summary}}
+// {{end}}
+//
+// The directory's own index page is not part of its collection. Every page,
+// in a collection or not, is also listed in .Site.Pages. Pages that aren't
+// being built, like drafts, are left out.
+
+const SUMMARY_LENGTH = 200
+
+var collections = make(map[string][]Page)
+
+var collectionReferencePattern = regexp.MustCompile(`Collections(?:\.([A-Za-z0-9_]+)|\s+"([^"]+)")`)
+var paragraphPattern = regexp.MustCompile(`(?s)<p>(.*?)</p>`)
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+func initializeCollections() error {
+	fmt.Println("Initializing collections...")
+
+	collections = make(map[string][]Page)
+	translations = make(map[string][]Page)
+	site.Collections = collections
+	site.Pages = nil
+
+	pagesDir := filepath.Join(config.Source, "pages")
+	if _, err := os.Stat(pagesDir); os.IsNotExist(err) {
+		return nil
+	}
+
+	err := filepath.Walk(pagesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		ext := filepath.Ext(path)
+		if ext != ".html" && ext != ".md" {
+			return nil
+		}
+
+		// a page with broken front matter fails when it is built
+		frontMatter, body, err := readFrontMatter(path)
+		if err != nil {
+			return nil
+		}
+
+		if !shouldBuild(frontMatter) {
+			return nil
+		}
+
+		page := newPage(path, frontMatter, pageDestPath(path, frontMatter))
+		page.Summary = summarize(frontMatter, body, ext)
+		site.Pages = append(site.Pages, page)
+
+		_, virtualPath := languageSourcePath(path)
+		name := collectionName(path)
+		if name == "" || strings.TrimSuffix(filepath.Base(virtualPath), ext) == "index" {
+			return nil
+		}
+		collections[name] = append(collections[name], page)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sortPages(site.Pages)
+	for name := range collections {
+		sortPages(collections[name])
+	}
+	initializeTranslations()
+
+	return nil
+}
+
+// collectionName returns the collection a page belongs to, or "" for pages
+// directly in src/pages.
+func collectionName(path string) string {
+	language, path := languageSourcePath(path)
+	rel, err := filepath.Rel(filepath.Join(config.Source, "pages"), filepath.Dir(path))
+	if err != nil || rel == "." || rel == language {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// collectionsUsedBy returns the names of the collections a template refers
+// to, either as .Site.Collections.blog or index .Site.Collections "blog".
+func collectionsUsedBy(content string) []string {
+	var names []string
+	for _, match := range collectionReferencePattern.FindAllStringSubmatch(content, -1) {
+		name := match[1] + match[2]
+		if !sliceContains(name, names) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func sortPages(pages []Page) {
+	sort.SliceStable(pages, func(i, j int) bool {
+		if !pages[i].Date.Equal(pages[j].Date) {
+			return pages[i].Date.After(pages[j].Date)
+		}
+		return pages[i].Title < pages[j].Title
+	})
+}
+
+// summarize uses the summary or description from front matter, and
+// otherwise the text of the page's first paragraph.
+func summarize(frontMatter FrontMatter, body []byte, ext string) string {
+	if summary, ok := frontMatter.Params["summary"].(string); ok {
+		return summary
+	}
+	if frontMatter.Description != "" {
+		return frontMatter.Description
+	}
+
+	if ext == ".md" {
+		body, _ = renderMarkdownWithShortcodes(body)
+	}
+
+	match := paragraphPattern.FindSubmatch(body)
+	if match == nil {
+		return ""
+	}
+
+	text := strings.Join(strings.Fields(tagPattern.ReplaceAllString(string(match[1]), "")), " ")
+	runes := []rune(text)
+	if len(runes) <= SUMMARY_LENGTH {
+		return text
+	}
+
+	truncated := string(runes[:SUMMARY_LENGTH])
+	if i := strings.LastIndex(truncated, " "); i > 0 {
+		truncated = truncated[:i]
+	}
+	return truncated + "…"
+}
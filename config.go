@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Project settings live in sssg.yaml (or sssg.yml or sssg.toml) next to
+// src. Every key is optional:
+//
+// source: src
+// output: dist
+// defaultLayout: Default
+// port: 8080
+// baseURL: https://example.com
+// title: My Site
+// author: Jane Doe
+// language: en
+// feedContent: full
+// prettyURLs: true
+// workers: 8
+// markdown:
+//   footnotes: false
+// highlight:
+//   theme: monokai
+// permalinks:
+//   blog: /blog/:year/:slug/
+// params:
+//   twitter: "@example"
+//
+// params are available to templates as .Site.Params. See permalinks.go for
+// prettyURLs and permalinks, workers.go for workers, markdown.go for
+// markdown and highlight.go for highlight.
+
+const DEFAULT_SOURCE = "src"
+const DEFAULT_OUTPUT = "dist"
+const DEFAULT_LAYOUT_NAME = "Default"
+const DEFAULT_PORT = 8080
+
+var CONFIG_FILES = []string{"sssg.yaml", "sssg.yml", "sssg.toml"}
+
+type Config struct {
+	Source        string                 `yaml:"source" toml:"source"`
+	Output        string                 `yaml:"output" toml:"output"`
+	DefaultLayout string                 `yaml:"defaultLayout" toml:"defaultLayout"`
+	Port          int                    `yaml:"port" toml:"port"`
+	BaseURL       string                 `yaml:"baseURL" toml:"baseURL"`
+	Title         string                 `yaml:"title" toml:"title"`
+	Author        string                 `yaml:"author" toml:"author"`
+	Language      string                 `yaml:"language" toml:"language"`
+	Languages     []string               `yaml:"languages" toml:"languages"`
+	FeedContent   string                 `yaml:"feedContent" toml:"feedContent"`
+	PrettyURLs    bool                   `yaml:"prettyURLs" toml:"prettyURLs"`
+	Robots        bool                   `yaml:"robots" toml:"robots"`
+	Fingerprint   bool                   `yaml:"fingerprint" toml:"fingerprint"`
+	Minify        bool                   `yaml:"minify" toml:"minify"`
+	Bundles       map[string][]string    `yaml:"bundles" toml:"bundles"`
+	Workers       int                    `yaml:"workers" toml:"workers"`
+	Markdown      MarkdownConfig         `yaml:"markdown" toml:"markdown"`
+	Highlight     HighlightConfig        `yaml:"highlight" toml:"highlight"`
+	Permalinks    map[string]string      `yaml:"permalinks" toml:"permalinks"`
+	Params        map[string]interface{} `yaml:"params" toml:"params"`
+}
+
+var config = defaultConfig()
+
+// configSectionKeys are the keys allowed in settings that are themselves
+// mappings of settings.
+var configSectionKeys = map[string][]string{
+	"markdown":  markdownKeys,
+	"highlight": highlightKeys,
+}
+
+func defaultConfig() Config {
+	return Config{
+		Source:        DEFAULT_SOURCE,
+		Output:        DEFAULT_OUTPUT,
+		DefaultLayout: DEFAULT_LAYOUT_NAME,
+		Port:          DEFAULT_PORT,
+		FeedContent:   "summary",
+		Robots:        true,
+		Markdown:      defaultMarkdownConfig(),
+		Highlight:     defaultHighlightConfig(),
+	}
+}
+
+// loadConfig reads the first config file it finds. Without one the
+// defaults are used.
+func loadConfig() (Config, error) {
+	cfg := defaultConfig()
+
+	for _, name := range CONFIG_FILES {
+		data, err := os.ReadFile(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return cfg, err
+		}
+
+		if strings.HasSuffix(name, ".toml") {
+			err = decodeTomlConfig(name, data, &cfg)
+		} else {
+			err = decodeYamlConfig(name, data, &cfg)
+		}
+		if err != nil {
+			return cfg, err
+		}
+
+		err = validateConfig(name, cfg)
+		cfg.Source = filepath.Clean(cfg.Source)
+		cfg.Output = filepath.Clean(cfg.Output)
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+func decodeYamlConfig(name string, data []byte, cfg *Config) error {
+	var document yaml.Node
+	err := yaml.Unmarshal(data, &document)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if len(document.Content) == 0 {
+		return nil
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s:%d: expected a mapping of settings", name, root.Line)
+	}
+	for i := 0; i < len(root.Content); i += 2 {
+		key := root.Content[i]
+		if !configKeyKnown(key.Value) {
+			return fmt.Errorf("%s:%d: unknown key %q", name, key.Line, key.Value)
+		}
+		value := root.Content[i+1]
+		if sectionKeys, found := configSectionKeys[key.Value]; found && value.Kind == yaml.MappingNode {
+			for j := 0; j < len(value.Content); j += 2 {
+				sectionKey := value.Content[j]
+				if !sliceContains(sectionKey.Value, sectionKeys) {
+					return fmt.Errorf("%s:%d: unknown key %q in %s", name, sectionKey.Line, sectionKey.Value, key.Value)
+				}
+			}
+		}
+	}
+
+	err = root.Decode(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+func decodeTomlConfig(name string, data []byte, cfg *Config) error {
+	metaData, err := toml.Decode(string(data), cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	for _, key := range metaData.Undecoded() {
+		if len(key) > 0 && (key[0] == "params" || key[0] == "permalinks") {
+			continue
+		}
+		return fmt.Errorf("%s: unknown key %q", name, key.String())
+	}
+	return nil
+}
+
+func configKeyKnown(key string) bool {
+	for _, known := range []string{"source", "output", "defaultLayout", "port", "baseURL", "title", "author", "language", "languages", "feedContent", "prettyURLs", "robots", "fingerprint", "minify", "bundles", "workers", "markdown", "highlight", "permalinks", "params"} {
+		if key == known {
+			return true
+		}
+	}
+	return false
+}
+
+func validateConfig(name string, cfg Config) error {
+	source := filepath.Clean(cfg.Source)
+	output := filepath.Clean(cfg.Output)
+
+	switch {
+	case strings.TrimSpace(cfg.Source) == "":
+		return fmt.Errorf("%s: source: must not be empty", name)
+	case strings.TrimSpace(cfg.Output) == "":
+		return fmt.Errorf("%s: output: must not be empty", name)
+	case output == "." || output == string(filepath.Separator):
+		return fmt.Errorf("%s: output: %q would delete the project on every build", name, cfg.Output)
+	case output == source || strings.HasPrefix(source, output+string(filepath.Separator)):
+		return fmt.Errorf("%s: output: %q would delete the source directory %q on every build", name, cfg.Output, cfg.Source)
+	case strings.TrimSpace(cfg.DefaultLayout) == "":
+		return fmt.Errorf("%s: defaultLayout: must not be empty", name)
+	case cfg.Port < 1 || cfg.Port > 65535:
+		return fmt.Errorf("%s: port: %d is not between 1 and 65535", name, cfg.Port)
+	case cfg.FeedContent != "summary" && cfg.FeedContent != "full":
+		return fmt.Errorf("%s: feedContent: %q must be summary or full", name, cfg.FeedContent)
+	case cfg.Workers < 0:
+		return fmt.Errorf("%s: workers: %d must not be negative", name, cfg.Workers)
+	}
+
+	err := validateLanguages(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	err = validateBundles(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	if cfg.Highlight.Enabled {
+		err := validateHighlightTheme(cfg.Highlight.Theme)
+		if err != nil {
+			return fmt.Errorf("%s: highlight.theme: %w", name, err)
+		}
+	}
+
+	for collection, pattern := range cfg.Permalinks {
+		err := validatePermalink(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: permalinks.%s: %w", name, collection, err)
+		}
+	}
+
+	if cfg.BaseURL != "" {
+		baseURL, err := url.Parse(cfg.BaseURL)
+		if err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+			return fmt.Errorf("%s: baseURL: %q must be an absolute URL like https://example.com", name, cfg.BaseURL)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Files in src/data are loaded into .Site.Data, keyed by their path without
+// the extension. src/data/nav.yaml is .Site.Data.nav and
+// src/data/team/members.json is .Site.Data.team.members:
+//
+// {{range .Site.Data.nav}}<a href="{{.url}}">{{.title}}</a>{{end}}
+//
+// Snippets get the site as .Site too. JSON, YAML and TOML files are
+// supported.
+
+var dataReferencePattern = regexp.MustCompile(`\.Data(?:\.([A-Za-z0-9_.]+)|\s+"([^"]+)"|\b)`)
+
+type DataFile struct {
+	Key  string
+	Path string
+}
+
+var dataFiles []DataFile
+
+func initializeData() error {
+	fmt.Println("Initializing data...")
+
+	site.Data = make(map[string]interface{})
+	dataFiles = []DataFile{}
+
+	dataDir := filepath.Join(config.Source, "data")
+	if _, err := os.Stat(dataDir); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.Walk(dataDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		value, err := readDataFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if value == nil {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dataDir, path)
+		if err != nil {
+			return err
+		}
+		keys := strings.Split(filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel))), "/")
+
+		parent := site.Data
+		for _, key := range keys[:len(keys)-1] {
+			child, found := parent[key]
+			if !found {
+				child = make(map[string]interface{})
+				parent[key] = child
+			}
+			childMap, ok := child.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: %q is already a data file", path, key)
+			}
+			parent = childMap
+		}
+
+		key := keys[len(keys)-1]
+		if _, found := parent[key]; found {
+			return fmt.Errorf("%s: %q is already loaded from another file or directory", path, key)
+		}
+		parent[key] = value
+
+		dataFiles = append(dataFiles, DataFile{Key: strings.Join(keys, "."), Path: path})
+		return nil
+	})
+}
+
+// readDataFile decodes a data file, returning nil for file types that
+// aren't data.
+func readDataFile(path string) (interface{}, error) {
+	var value interface{}
+
+	switch filepath.Ext(path) {
+	case ".json":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(data, &value)
+		return value, err
+	case ".yaml", ".yml":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		err = yaml.Unmarshal(data, &value)
+		return value, err
+	case ".toml":
+		var table map[string]interface{}
+		_, err := toml.DecodeFile(path, &table)
+		return table, err
+	}
+
+	return nil, nil
+}
+
+// dataUsedBy returns the data files a template refers to. A reference to
+// .Site.Data.team uses every file under src/data/team, and a bare .Site.Data
+// uses them all.
+func dataUsedBy(content string) []DataFile {
+	var used []DataFile
+	for _, match := range dataReferencePattern.FindAllStringSubmatch(content, -1) {
+		reference := match[1] + match[2]
+		for _, file := range dataFiles {
+			if reference == "" || file.Key == reference || strings.HasPrefix(file.Key, reference+".") || strings.HasPrefix(reference, file.Key+".") {
+				if !dataFileFound(file, used) {
+					used = append(used, file)
+				}
+			}
+		}
+	}
+	return used
+}
+
+func dataFileFound(file DataFile, found []DataFile) bool {
+	for _, f := range found {
+		if f.Path == file.Path {
+			return true
+		}
+	}
+	return false
+}
@@ -66,7 +66,7 @@ func deploy(domain string, env string) {
 	}
 
 	fmt.Println("Deploying", env)
-	localDir := "./dist/"
+	localDir := config.Output
 
 	token := os.Getenv("DEPLOY_TOKEN")
 	if token == "" {
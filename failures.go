@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A build that fails returns a BuildError listing every file that failed and
+// why, and sssg exits with a non-zero status. Every page is rendered either
+// way. By default nothing is written unless every page built, so a failed
+// build doesn't leave dist half updated. sssg -build -keep-going writes the
+// pages that built and the generated pages as well. sssg dev always keeps
+// going.
+
+var keepGoing bool
+
+// heldWrite is a page that writePage holds back until every page has built,
+// or a directory that removeFromOutput holds back from deleting.
+type heldWrite struct {
+	SrcPath  string
+	DestPath string
+	Data     []byte
+	Remove   bool
+}
+
+var holdWrites bool
+var heldWrites []heldWrite
+var heldWritesMutex sync.Mutex
+
+type PageError struct {
+	Path string
+	Err  error
+}
+
+func (e PageError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e PageError) Unwrap() error {
+	return e.Err
+}
+
+type BuildError struct {
+	Failures []PageError
+}
+
+func (e *BuildError) Error() string {
+	var message strings.Builder
+	fmt.Fprintf(&message, "%s failed:", plural(len(e.Failures), "file"))
+	for _, failure := range e.Failures {
+		fmt.Fprintf(&message, "\n  %s", failure.Error())
+	}
+	return message.String()
+}
+
+func (e *BuildError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, failure := range e.Failures {
+		errs[i] = failure
+	}
+	return errs
+}
+
+// failure is a one item list for err, or nil when err is nil.
+func failure(path string, err error) []PageError {
+	if err == nil {
+		return nil
+	}
+	return []PageError{{Path: path, Err: err}}
+}
+
+// sortFailures sorts by path so the list is the same however the workers
+// were scheduled.
+func sortFailures(failures []PageError) {
+	sort.SliceStable(failures, func(i, j int) bool {
+		return failures[i].Path < failures[j].Path
+	})
+}
+
+// reportFailures is for the file watcher, which reports errors and carries on.
+func reportFailures(failures []PageError) {
+	for _, failure := range failures {
+		fmt.Println("Error:", failure.Error())
+	}
+}
+
+// flushHeldWrites writes the pages held back while building.
+func flushHeldWrites() []PageError {
+	holdWrites = false
+	var failures []PageError
+	for _, held := range heldWrites {
+		if held.Remove {
+			failures = append(failures, failure(held.DestPath, removeFromOutput(held.DestPath))...)
+			continue
+		}
+		failures = append(failures, failure(held.DestPath, writePage(held.SrcPath, held.DestPath, held.Data))...)
+	}
+	heldWrites = nil
+	return failures
+}
+
+// removeFromOutput deletes a stale file or directory in dist, unless writes
+// are being held back.
+func removeFromOutput(destPath string) error {
+	if holdWrites {
+		heldWritesMutex.Lock()
+		heldWrites = append(heldWrites, heldWrite{DestPath: destPath, Remove: true})
+		heldWritesMutex.Unlock()
+		return nil
+	}
+
+	fmt.Println("Deleting from dist:", destPath)
+	return os.RemoveAll(destPath)
+}
+
+func discardHeldWrites() {
+	holdWrites = false
+	heldWrites = nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Every collection gets an RSS 2.0 feed at /<collection>/feed.xml and an
+// Atom feed at /<collection>/atom.xml with its newest FEED_LIMIT pages.
+// Feed readers need absolute links, so set baseURL in the config. The site's
+// title, author and language are used when set. Entries carry the page
+// summary, and also the full rendered page with `feedContent: full`.
+
+const FEED_LIMIT = 20
+
+type rssFeed struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	ContentNS string     `xml:"xmlns:content,attr,omitempty"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title          string   `xml:"title"`
+	Link           string   `xml:"link"`
+	GUID           rssGUID  `xml:"guid"`
+	PubDate        string   `xml:"pubDate,omitempty"`
+	Description    string   `xml:"description"`
+	ContentEncoded *cdata   `xml:"content:encoded,omitempty"`
+	Categories     []string `xml:"category"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published,omitempty"`
+	Updated    string         `xml:"updated"`
+	Summary    string         `xml:"summary,omitempty"`
+	Content    *atomContent   `xml:"content,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+func buildFeeds() []PageError {
+	var failures []PageError
+	for name := range collections {
+		failures = append(failures, buildCollectionFeeds(name)...)
+	}
+	return failures
+}
+
+func buildCollectionFeeds(name string) []PageError {
+	var pages []Page
+	for _, page := range collections[name] {
+		if isPublished(page.FrontMatter) {
+			pages = append(pages, page)
+		}
+	}
+	if len(pages) == 0 {
+		return nil
+	}
+	if len(pages) > FEED_LIMIT {
+		pages = pages[:FEED_LIMIT]
+	}
+
+	if site.BaseURL == "" {
+		fmt.Println("Warning: baseURL is not set in the config so the links in the", name, "feeds are not absolute")
+	}
+
+	title := feedTitle(name)
+	link := siteURL("/" + name + "/")
+	rssURL := siteURL("/" + name + "/feed.xml")
+	atomURL := siteURL("/" + name + "/atom.xml")
+	fullContent := config.FeedContent == "full"
+
+	// pages are sorted newest first
+	updated := buildTime
+	for _, page := range pages {
+		if !page.Date.IsZero() {
+			updated = page.Date
+			break
+		}
+	}
+
+	rss := rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         title,
+			Link:          link,
+			Description:   title,
+			Language:      pages[0].Language,
+			LastBuildDate: updated.Format(time.RFC1123Z),
+			AtomLink:      atomLink{Href: rssURL, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+
+	if fullContent {
+		rss.ContentNS = "http://purl.org/rss/1.0/modules/content/"
+	}
+
+	author := site.Author
+	if author == "" {
+		author = title
+	}
+
+	atom := atomFeed{
+		Title:   title,
+		ID:      link,
+		Updated: updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: atomURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: link, Rel: "alternate", Type: "text/html"},
+		},
+		Author: atomAuthor{Name: author},
+	}
+
+	var failures []PageError
+	for _, page := range pages {
+		var content string
+		if fullContent {
+			var err error
+			content, err = feedContent(page)
+			failures = append(failures, failure(page.SourcePath, err)...)
+		}
+
+		item := rssItem{
+			Title:       page.Title,
+			Link:        page.URL,
+			GUID:        rssGUID{IsPermaLink: site.BaseURL != "", Value: page.URL},
+			Description: page.Summary,
+			Categories:  page.Tags,
+		}
+		if !page.Date.IsZero() {
+			item.PubDate = page.Date.Format(time.RFC1123Z)
+		}
+		if content != "" {
+			item.ContentEncoded = &cdata{Value: content}
+		}
+		rss.Channel.Items = append(rss.Channel.Items, item)
+
+		entryUpdated := page.Date
+		if entryUpdated.IsZero() {
+			entryUpdated = updated
+		}
+		entry := atomEntry{
+			Title:   page.Title,
+			ID:      page.URL,
+			Link:    atomLink{Href: page.URL, Rel: "alternate", Type: "text/html"},
+			Updated: entryUpdated.Format(time.RFC3339),
+			Summary: page.Summary,
+		}
+		if !page.Date.IsZero() {
+			entry.Published = page.Date.Format(time.RFC3339)
+		}
+		if content != "" {
+			entry.Content = &atomContent{Type: "html", Value: content}
+		}
+		for _, tag := range page.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		atom.Entries = append(atom.Entries, entry)
+	}
+
+	rssPath := filepath.Join(config.Output, name, "feed.xml")
+	atomPath := filepath.Join(config.Output, name, "atom.xml")
+	failures = append(failures, failure(rssPath, writeXML(rssPath, rss))...)
+	failures = append(failures, failure(atomPath, writeXML(atomPath, atom))...)
+	return failures
+}
+
+// feedTitle uses the title of the collection's index page if it has one.
+func feedTitle(name string) string {
+	title := capitalize(filepath.Base(name))
+	indexPath := "/" + name + "/"
+	for _, page := range site.Pages {
+		if page.Path == indexPath && page.Title != "" {
+			title = page.Title
+		}
+	}
+	if site.Title != "" {
+		title = site.Title + " - " + title
+	}
+	return title
+}
+
+func feedContent(page Page) (string, error) {
+	_, body, err := readFrontMatter(page.SourcePath)
+	if err != nil {
+		return "", err
+	}
+	content, err := renderContent(page.SourcePath, body, page)
+	return string(content), err
+}
+
+func siteURL(path string) string {
+	return strings.TrimSuffix(site.BaseURL, "/") + path
+}
+
+func writeXML(destPath string, v interface{}) error {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writePage("(generated)", destPath, append([]byte(xml.Header), append(data, '\n')...))
+}
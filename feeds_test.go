@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// testRSS and testAtom read back the feeds, with every <link> in one list
+// because the channel has both an RSS <link> and an <atom:link>.
+type testRSS struct {
+	Channel struct {
+		Title       string     `xml:"title"`
+		Links       []testLink `xml:"link"`
+		Description string     `xml:"description"`
+		Items       []struct {
+			Title   string `xml:"title"`
+			Link    string `xml:"link"`
+			GUID    string `xml:"guid"`
+			PubDate string `xml:"pubDate"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+type testAtom struct {
+	Title   string     `xml:"title"`
+	ID      string     `xml:"id"`
+	Updated string     `xml:"updated"`
+	Links   []testLink `xml:"link"`
+	Author  struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Entries []struct {
+		Title   string   `xml:"title"`
+		ID      string   `xml:"id"`
+		Link    testLink `xml:"link"`
+		Updated string   `xml:"updated"`
+	} `xml:"entry"`
+}
+
+type testLink struct {
+	XMLName xml.Name
+	Href    string `xml:"href,attr"`
+	Rel     string `xml:"rel,attr"`
+	Value   string `xml:",chardata"`
+}
+
+// newTestCollection sets up a blog collection with two dated posts, newest
+// first.
+func newTestCollection(t *testing.T, baseURL string) {
+	newTestSite(t, nil)
+	config.BaseURL = baseURL
+	site = Site{Title: "Example", BaseURL: baseURL, Author: "Jane Doe"}
+
+	first := newPage("src/pages/blog/first.md", FrontMatter{Title: "First", Date: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)}, "dist/blog/first.html")
+	second := newPage("src/pages/blog/second.md", FrontMatter{Title: "Second", Date: time.Date(2024, 3, 4, 12, 30, 0, 0, time.UTC)}, "dist/blog/second.html")
+	collections = map[string][]Page{"blog": {second, first}}
+	site.Pages = []Page{second, first}
+}
+
+func readFeed(t *testing.T, name string, v interface{}) {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(config.Output, "blog", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = xml.Unmarshal(data, v)
+	if err != nil {
+		t.Fatalf("%s: %v\n%s", name, err, data)
+	}
+}
+
+func findLink(links []testLink, space string) testLink {
+	for _, link := range links {
+		if link.XMLName.Space == space {
+			return link
+		}
+	}
+	return testLink{}
+}
+
+func TestCollectionFeeds(t *testing.T) {
+	newTestCollection(t, "https://example.com/")
+
+	failures := buildCollectionFeeds("blog")
+	if len(failures) > 0 {
+		t.Fatal(failures)
+	}
+
+	var rss testRSS
+	readFeed(t, "feed.xml", &rss)
+	channel := rss.Channel
+	if channel.Title != "Example - Blog" || channel.Description != "Example - Blog" {
+		t.Errorf("got title %q and description %q, want Example - Blog", channel.Title, channel.Description)
+	}
+	if link := findLink(channel.Links, ""); link.Value != "https://example.com/blog/" {
+		t.Errorf("got channel link %q", link.Value)
+	}
+	if self := findLink(channel.Links, atomNamespace); self.Rel != "self" || self.Href != "https://example.com/blog/feed.xml" {
+		t.Errorf("got atom:link %+v, want rel=self to the feed", self)
+	}
+	if len(channel.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(channel.Items))
+	}
+	item := channel.Items[0]
+	if item.Title != "Second" || item.Link != "https://example.com/blog/second.html" || item.GUID != item.Link {
+		t.Errorf("got item %+v", item)
+	}
+	pubDate, err := time.Parse(time.RFC1123Z, item.PubDate)
+	if err != nil || !pubDate.Equal(time.Date(2024, 3, 4, 12, 30, 0, 0, time.UTC)) {
+		t.Errorf("got pubDate %q, want an RFC 1123 date with a numeric zone: %v", item.PubDate, err)
+	}
+
+	var atom testAtom
+	readFeed(t, "atom.xml", &atom)
+	if atom.ID != "https://example.com/blog/" {
+		t.Errorf("got feed id %q", atom.ID)
+	}
+	if atom.Updated != "2024-03-04T12:30:00Z" {
+		t.Errorf("got feed updated %q, want the newest post's date", atom.Updated)
+	}
+	if atom.Author.Name != "Jane Doe" {
+		t.Errorf("got author %q", atom.Author.Name)
+	}
+	self := testLink{}
+	for _, link := range atom.Links {
+		if link.Rel == "self" {
+			self = link
+		}
+	}
+	if self.Href != "https://example.com/blog/atom.xml" {
+		t.Errorf("got self link %q", self.Href)
+	}
+	if len(atom.Entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(atom.Entries))
+	}
+	entry := atom.Entries[1]
+	if entry.ID != "https://example.com/blog/first.html" || entry.Link.Href != entry.ID || entry.Updated != "2024-01-02T10:00:00Z" {
+		t.Errorf("got entry %+v", entry)
+	}
+}
+
+func TestCollectionFeedsWithoutBaseURL(t *testing.T) {
+	newTestCollection(t, "")
+
+	failures := buildCollectionFeeds("blog")
+	if len(failures) > 0 {
+		t.Fatal(failures)
+	}
+
+	var rss testRSS
+	readFeed(t, "feed.xml", &rss)
+	if link := findLink(rss.Channel.Links, ""); link.Value != "/blog/" {
+		t.Errorf("got channel link %q, want /blog/", link.Value)
+	}
+	if self := findLink(rss.Channel.Links, atomNamespace); self.Href != "/blog/feed.xml" {
+		t.Errorf("got atom:link %q, want /blog/feed.xml", self.Href)
+	}
+	if rss.Channel.Items[0].Link != "/blog/second.html" {
+		t.Errorf("got item link %q, want /blog/second.html", rss.Channel.Items[0].Link)
+	}
+
+	var atom testAtom
+	readFeed(t, "atom.xml", &atom)
+	if atom.ID != "/blog/" || atom.Entries[0].ID != "/blog/second.html" {
+		t.Errorf("got feed id %q and entry id %q, want relative links", atom.ID, atom.Entries[0].ID)
+	}
+}
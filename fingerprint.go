@@ -0,0 +1,230 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// With `fingerprint: true` in sssg.yaml, sssg build puts a hash of each
+// asset's content in its name, so src/assets/js/app.js is written to
+// dist/assets/js/app.3f9a1c2b.js and can be cached forever. References to
+// /assets/... in the built HTML and CSS, and relative url()s in stylesheets,
+// are rewritten to the new names, and dist/assets-manifest.json maps each
+// name to its fingerprinted one for anything else that needs them:
+//
+// {
+//   "assets/js/app.js": "assets/js/app.3f9a1c2b.js"
+// }
+//
+// Assets are written under their plain names too, so references that can't
+// be rewritten, like paths put together in a script, still work. sssg dev
+// keeps the plain names.
+
+const ASSETS_MANIFEST = "assets-manifest.json"
+const ASSETS_DEPENDENCY = "(assets)"
+const FINGERPRINT_LENGTH = 8
+
+var useFingerprints = true
+
+// assetManifest maps /assets/js/app.js to /assets/js/app.3f9a1c2b.js.
+var assetManifest = make(map[string]string)
+
+var assetReferencePattern = regexp.MustCompile(`/assets/[^"'()\s?#<>,]+`)
+var cssRelativeReferencePattern = regexp.MustCompile(`(url\(\s*["']?|@import\s+["'])([^"'()\s?#]+)`)
+
+func fingerprinting() bool {
+	return config.Fingerprint && useFingerprints
+}
+
+// initializeAssets works out the fingerprinted name of every asset before any
+// page is built. A stylesheet's name comes from its content after its own
+// references are rewritten, so it changes when an image it uses does.
+func initializeAssets() error {
+	assetManifest = make(map[string]string)
+	if !fingerprinting() {
+		return nil
+	}
+
+	fmt.Println("Fingerprinting assets...")
+
+	stylesheets := make(map[string][]byte)
+
+	dir := filepath.Join(config.Source, "assets")
+	if _, err := os.Stat(dir); err == nil {
+		err := filepath.Walk(dir, func(srcPath string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			data, err := os.ReadFile(srcPath)
+			if err != nil {
+				return err
+			}
+
+			name := assetURL(srcPath)
+			if path.Ext(name) == ".css" {
+				stylesheets[name] = data
+			} else {
+				assetManifest[name] = fingerprintedName(name, data)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for name := range config.Bundles {
+		data, err := bundleContent(name)
+		if err != nil {
+			return fmt.Errorf("bundles.%s: %w", name, err)
+		}
+		if path.Ext(name) == ".css" {
+			stylesheets[bundleURL(name)] = data
+		} else {
+			assetManifest[bundleURL(name)] = fingerprintedName(bundleURL(name), data)
+		}
+	}
+
+	if generatesSyntaxStylesheet() {
+		css, err := syntaxStylesheet()
+		if err != nil {
+			return err
+		}
+		stylesheets["/"+SYNTAX_STYLESHEET] = []byte(css)
+	}
+
+	// stylesheets can @import each other, so go round until the names settle
+	for i := 0; i <= len(stylesheets); i++ {
+		changed := false
+		for name, data := range stylesheets {
+			fingerprinted := fingerprintedName(name, rewriteStylesheet(name, data))
+			if assetManifest[name] != fingerprinted {
+				assetManifest[name] = fingerprinted
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	return nil
+}
+
+// assetURL turns src/assets/js/app.js into /assets/js/app.js.
+func assetURL(srcPath string) string {
+	rel, err := filepath.Rel(config.Source, srcPath)
+	if err != nil {
+		rel = srcPath
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// fingerprintedName turns /assets/js/app.js into /assets/js/app.3f9a1c2b.js.
+func fingerprintedName(name string, data []byte) string {
+	sum := sha256.Sum256(data)
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:])[:FINGERPRINT_LENGTH] + ext
+}
+
+// assetOutputPath is where the asset served as name is written.
+func assetOutputPath(name string) string {
+	if fingerprinted, found := assetManifest[name]; found {
+		name = fingerprinted
+	}
+	return filepath.Join(config.Output, filepath.FromSlash(name))
+}
+
+// writeAsset writes the asset served as name under its fingerprinted name,
+// and under its plain name as well.
+func writeAsset(srcPath string, name string, data []byte) error {
+	destPath := assetOutputPath(name)
+	err := writePage(srcPath, destPath, data)
+	if plainPath := filepath.Join(config.Output, filepath.FromSlash(name)); plainPath != destPath {
+		err = errors.Join(err, writePage(srcPath, plainPath, data))
+	}
+	return err
+}
+
+// rewriteAssetReferences swaps the asset paths in built HTML or CSS for
+// their fingerprinted names.
+func rewriteAssetReferences(data []byte) []byte {
+	if len(assetManifest) == 0 {
+		return data
+	}
+
+	return assetReferencePattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		if fingerprinted, found := assetManifest[string(match)]; found {
+			return []byte(fingerprinted)
+		}
+		return match
+	})
+}
+
+// rewriteStylesheet is rewriteAssetReferences for the stylesheet served as
+// name, which also rewrites url(../images/logo.png) and @import "reset.css"
+// relative to where the stylesheet is.
+func rewriteStylesheet(name string, data []byte) []byte {
+	data = rewriteAssetReferences(data)
+	if len(assetManifest) == 0 {
+		return data
+	}
+
+	return cssRelativeReferencePattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := cssRelativeReferencePattern.FindSubmatch(match)
+		reference := string(groups[2])
+		if strings.HasPrefix(reference, "/") || strings.Contains(reference, ":") {
+			return match
+		}
+		fingerprinted, found := assetManifest[path.Join(path.Dir(name), reference)]
+		if !found {
+			return match
+		}
+		return append(groups[1], path.Join(path.Dir(reference), path.Base(fingerprinted))...)
+	})
+}
+
+func buildAssetManifest() []PageError {
+	if !fingerprinting() {
+		return nil
+	}
+
+	destPath := filepath.Join(config.Output, ASSETS_MANIFEST)
+
+	manifest := make(map[string]string)
+	for name, fingerprinted := range assetManifest {
+		manifest[strings.TrimPrefix(name, "/")] = strings.TrimPrefix(fingerprinted, "/")
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return failure(destPath, err)
+	}
+	return failure(destPath, writePage("(generated)", destPath, append(data, '\n')))
+}
+
+// writeAssetsHash covers the fingerprinted names, which pages that refer to
+// assets are built with.
+func writeAssetsHash(h hash.Hash) {
+	var names []string
+	for name := range assetManifest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(h, "%s %s\n", name, assetManifest[name])
+	}
+}
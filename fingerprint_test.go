@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestFingerprintedStylesheetReferences(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"src/layouts/Default.html":   "<html><head><link rel=\"stylesheet\" href=\"/assets/css/styles.css\"></head><body>__CONTENT__</body></html>",
+		"src/pages/index.html":       "<p>Home</p>",
+		"src/assets/css/styles.css":  "@import \"reset.css\";\nbody { background: url(../images/logo.png); }\nh1 { background: url('/assets/images/logo.png'); }\n",
+		"src/assets/css/reset.css":   "body { margin: 0; }\n",
+		"src/assets/images/logo.png": "not really a png",
+		"src/assets/js/app.js":       "fetch('/assets/' + 'data.json');\n",
+	})
+	config.Fingerprint = true
+
+	err := build(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	styles := filepath.Join(config.Output, filepath.FromSlash(assetManifest["/assets/css/styles.css"]))
+	data, err := os.ReadFile(styles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pattern := range []string{
+		`@import "reset\.[0-9a-f]{8}\.css"`,
+		`url\(\.\./images/logo\.[0-9a-f]{8}\.png\)`,
+		`url\('/assets/images/logo\.[0-9a-f]{8}\.png'\)`,
+	} {
+		if !regexp.MustCompile(pattern).Match(data) {
+			t.Errorf("%s doesn't match %s:\n%s", styles, pattern, data)
+		}
+	}
+
+	for _, name := range []string{"assets/css/styles.css", "assets/images/logo.png", "assets/js/app.js"} {
+		if _, err := os.Stat(filepath.Join(config.Output, filepath.FromSlash(name))); err != nil {
+			t.Errorf("the plain copy of %s wasn't written: %v", name, err)
+		}
+	}
+}
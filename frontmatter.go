@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A page may start with a YAML block delimited by "---" lines:
+//
+// ---
+// title: About us
+// layout: Blog
+// tags: [go, ssg]
+// ---
+// <h1>About us</h1>
+//
+// The block is parsed into a FrontMatter and stripped from the page body.
+// Keys that are not fields of FrontMatter end up in Params.
+//
+// An HTML page with front matter is a template, so it can list collections
+// and use {{.Title}}. Pages without front matter, or with `template: false`,
+// are copied as they are and can contain {{ }} for client-side frameworks.
+
+const FRONT_MATTER_DELIMITER = "---"
+
+var frontMatterDateKeys = []string{"date", "publishDate", "expiryDate"}
+
+func parseFrontMatter(data []byte) (FrontMatter, []byte, error) {
+	var frontMatter FrontMatter
+
+	firstLine, rest, found := bytes.Cut(data, []byte("\n"))
+	if !found || string(bytes.TrimSpace(firstLine)) != FRONT_MATTER_DELIMITER {
+		return frontMatter, data, nil
+	}
+
+	var yamlBlock []byte
+	closed := false
+	for len(rest) > 0 {
+		var line []byte
+		line, rest, _ = bytes.Cut(rest, []byte("\n"))
+		if string(bytes.TrimSpace(line)) == FRONT_MATTER_DELIMITER {
+			closed = true
+			break
+		}
+		yamlBlock = append(yamlBlock, line...)
+		yamlBlock = append(yamlBlock, '\n')
+	}
+
+	if !closed {
+		return frontMatter, data, fmt.Errorf("front matter is missing its closing %q", FRONT_MATTER_DELIMITER)
+	}
+
+	var document yaml.Node
+	err := yaml.Unmarshal(yamlBlock, &document)
+	if err != nil {
+		return frontMatter, data, fmt.Errorf("invalid front matter: %w", err)
+	}
+
+	if len(document.Content) > 0 {
+		tagQuotedDates(document.Content[0])
+		err = document.Content[0].Decode(&frontMatter)
+		if err != nil {
+			return frontMatter, data, fmt.Errorf("invalid front matter: %w", err)
+		}
+	}
+
+	if frontMatter.Template == nil {
+		template := true
+		frontMatter.Template = &template
+	}
+
+	return frontMatter, rest, nil
+}
+
+// isTemplate reports whether an HTML page is rendered with html/template.
+func isTemplate(frontMatter FrontMatter) bool {
+	return frontMatter.Template != nil && *frontMatter.Template
+}
+
+func readFrontMatter(path string) (FrontMatter, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return FrontMatter{}, nil, err
+	}
+	return parseFrontMatter(data)
+}
+
+// tagQuotedDates lets authors write `date: "2024-03-01"` as well as the
+// unquoted form by treating quoted values of date keys as YAML timestamps.
+func tagQuotedDates(node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		value := node.Content[i+1]
+		if sliceContains(key.Value, frontMatterDateKeys) && value.Kind == yaml.ScalarNode && value.Tag == "!!str" {
+			value.Tag = "!!timestamp"
+		}
+	}
+}
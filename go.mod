@@ -4,8 +4,16 @@ go 1.22.1
 
 require github.com/fsnotify/fsnotify v1.7.0
 
-require github.com/russross/blackfriday/v2 v2.1.0
+require github.com/yuin/goldmark v1.8.2
+
+require github.com/alecthomas/chroma/v2 v2.24.1
 
 require github.com/joho/godotenv v1.5.1
 
+require gopkg.in/yaml.v3 v3.0.1
+
+require github.com/BurntSushi/toml v1.6.0
+
+require github.com/dlclark/regexp2 v1.12.0 // indirect
+
 require golang.org/x/sys v0.4.0 // indirect
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// Fenced code blocks in markdown and <pre><code class="language-go"> blocks
+// in HTML pages are highlighted at build time. The markup uses classes, and
+// the colors for the chosen theme are written to /assets/css/syntax.css
+// (unless src/assets/css/syntax.css exists), so link that in your layout.
+//
+// Line numbers and highlighted lines are set per block after the language:
+//
+// ```go {3-5,8 linenos}
+//
+// sssg.yaml picks the theme and whether blocks have line numbers by default:
+//
+// highlight:
+//   enabled: true
+//   theme: github
+//   lineNumbers: false
+
+type HighlightConfig struct {
+	Enabled     bool   `yaml:"enabled" toml:"enabled"`
+	Theme       string `yaml:"theme" toml:"theme"`
+	LineNumbers bool   `yaml:"lineNumbers" toml:"lineNumbers"`
+}
+
+const SYNTAX_STYLESHEET = "assets/css/syntax.css"
+
+var highlightKeys = []string{"enabled", "theme", "lineNumbers"}
+
+var codeBlockPattern = regexp.MustCompile(`(?s)<pre><code class="language-([^"\s]+)">(.*?)</code></pre>`)
+var codeOptionsPattern = regexp.MustCompile(`\{([^}]*)\}\s*$`)
+var lineRangePattern = regexp.MustCompile(`^(\d+)(?:-(\d+))?$`)
+
+func defaultHighlightConfig() HighlightConfig {
+	return HighlightConfig{
+		Enabled: true,
+		Theme:   "github",
+	}
+}
+
+func validateHighlightTheme(theme string) error {
+	if _, found := styles.Registry[theme]; !found {
+		return fmt.Errorf("%q is not a theme, try one of %s", theme, strings.Join(styles.Names(), ", "))
+	}
+	return nil
+}
+
+// parseCodeInfo splits a fence's info string like `go {3-5 linenos}` into the
+// language, whether to number the lines and the lines to highlight.
+func parseCodeInfo(info string) (string, bool, [][2]int, error) {
+	lineNumbers := config.Highlight.LineNumbers
+	var ranges [][2]int
+
+	options := ""
+	if match := codeOptionsPattern.FindStringSubmatch(info); match != nil {
+		options = match[1]
+		info = info[:len(info)-len(match[0])]
+	}
+	language := strings.TrimSpace(info)
+	if fields := strings.Fields(language); len(fields) > 0 {
+		language = fields[0]
+	}
+
+	for _, option := range strings.FieldsFunc(options, func(r rune) bool { return r == ',' || r == ' ' }) {
+		switch option {
+		case "linenos":
+			lineNumbers = true
+		case "nolinenos":
+			lineNumbers = false
+		default:
+			match := lineRangePattern.FindStringSubmatch(option)
+			if match == nil {
+				return language, lineNumbers, ranges, fmt.Errorf("code block option %q should be a line like 3, a range like 3-5, linenos or nolinenos", option)
+			}
+			start, _ := strconv.Atoi(match[1])
+			end := start
+			if match[2] != "" {
+				end, _ = strconv.Atoi(match[2])
+			}
+			ranges = append(ranges, [2]int{start, end})
+		}
+	}
+
+	return language, lineNumbers, ranges, nil
+}
+
+// highlightCode returns the highlighted markup for code, or false when
+// highlighting is off or the language is unknown.
+func highlightCode(code string, info string) (string, bool, error) {
+	if !config.Highlight.Enabled {
+		return "", false, nil
+	}
+
+	language, lineNumbers, ranges, err := parseCodeInfo(info)
+	if err != nil {
+		return "", false, err
+	}
+
+	lexer := lexers.Get(language)
+	if language == "" || lexer == nil {
+		return "", false, nil
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, code)
+	if err != nil {
+		return "", false, err
+	}
+
+	formatter := chromahtml.New(
+		chromahtml.WithClasses(true),
+		chromahtml.WithLineNumbers(lineNumbers),
+		chromahtml.LineNumbersInTable(true),
+		chromahtml.HighlightLines(ranges),
+	)
+
+	var highlighted strings.Builder
+	err = formatter.Format(&highlighted, styles.Get(config.Highlight.Theme), iterator)
+	if err != nil {
+		return "", false, err
+	}
+	return highlighted.String(), true, nil
+}
+
+// highlightHTML highlights the <pre><code class="language-x"> blocks of an
+// HTML page.
+func highlightHTML(data []byte) ([]byte, error) {
+	if !config.Highlight.Enabled {
+		return data, nil
+	}
+
+	var highlightErr error
+	data = codeBlockPattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := codeBlockPattern.FindSubmatch(match)
+		highlighted, ok, err := highlightCode(html.UnescapeString(string(groups[2])), string(groups[1]))
+		if err != nil {
+			highlightErr = err
+		}
+		if !ok {
+			return match
+		}
+		return []byte(highlighted)
+	})
+	return data, highlightErr
+}
+
+// generatesSyntaxStylesheet reports whether the build writes syntax.css,
+// which it does unless the source has its own.
+func generatesSyntaxStylesheet() bool {
+	if !config.Highlight.Enabled {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(config.Source, filepath.FromSlash(SYNTAX_STYLESHEET)))
+	return err != nil
+}
+
+func syntaxStylesheet() (string, error) {
+	var css strings.Builder
+	formatter := chromahtml.New(chromahtml.WithClasses(true), chromahtml.WithLineNumbers(true), chromahtml.LineNumbersInTable(true))
+	err := formatter.WriteCSS(&css, styles.Get(config.Highlight.Theme))
+	return css.String(), err
+}
+
+func buildSyntaxStylesheet() []PageError {
+	if !generatesSyntaxStylesheet() {
+		return nil
+	}
+
+	destPath := assetOutputPath("/" + SYNTAX_STYLESHEET)
+	css, err := syntaxStylesheet()
+	if err != nil {
+		return failure(destPath, err)
+	}
+	return failure(destPath, writeAsset("(generated)", "/"+SYNTAX_STYLESHEET, []byte(css)))
+}
+
+// codeBlockRenderer highlights fenced code blocks in markdown and renders the
+// ones it can't highlight the way goldmark does.
+type codeBlockRenderer struct{}
+
+func (r *codeBlockRenderer) RegisterFuncs(registerer renderer.NodeRendererFuncRegisterer) {
+	registerer.Register(ast.KindFencedCodeBlock, r.renderFencedCodeBlock)
+}
+
+func (r *codeBlockRenderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	block := node.(*ast.FencedCodeBlock)
+	info := ""
+	if block.Info != nil {
+		info = string(block.Info.Segment.Value(source))
+	}
+
+	var code strings.Builder
+	lines := block.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		code.Write(line.Value(source))
+	}
+
+	highlighted, ok, err := highlightCode(code.String(), info)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	if ok {
+		w.WriteString(highlighted)
+		return ast.WalkSkipChildren, nil
+	}
+
+	w.WriteString("<pre><code")
+	if language := block.Language(source); language != nil {
+		fmt.Fprintf(w, ` class="language-%s"`, util.EscapeHTML(language))
+	}
+	w.WriteByte('>')
+	w.Write(util.EscapeHTML([]byte(code.String())))
+	w.WriteString("</code></pre>\n")
+	return ast.WalkSkipChildren, nil
+}
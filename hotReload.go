@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -22,7 +23,7 @@ func fileWatcher() {
 
 	fmt.Println("Watching for changes...")
 
-	err = watchPath(watcher, SRC)
+	err = watchPath(watcher, config.Source)
 	if err != nil {
 		log.Fatal("Error watching path:", err)
 	}
@@ -50,6 +51,39 @@ func fileWatcher() {
 
 			if strings.HasSuffix(event.Name, ".DS_Store") {
 				// IGNORE
+			} else if filepath.Clean(event.Name) == redirectsPath() && event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) != 0 {
+				// CREATE, UPDATE OR DELETE REDIRECTS
+				interestingEvent = true
+				err = initializeRedirects()
+				if err != nil {
+					fmt.Println("Error initializing redirects:", err)
+				}
+				reportFailures(buildRedirects())
+			} else if inSrcDir(event.Name, "data") && (fileInfo == nil || !fileInfo.IsDir()) && event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) != 0 {
+				// CREATE, UPDATE OR DELETE DATA
+				interestingEvent = true
+				dependents := dependencies[event.Name]
+
+				err = initializeData()
+				if err != nil {
+					fmt.Println("Error initializing data:", err)
+				}
+
+				err = initializeDependencies()
+				if err != nil {
+					log.Fatal("Error initializing dependencies:", err)
+				}
+
+				for _, path := range dependencies[event.Name] {
+					if !sliceContains(path, dependents) {
+						dependents = append(dependents, path)
+					}
+				}
+
+				for _, path := range dependents {
+					wg.Add(1)
+					go rebuildPage(path, &wg)
+				}
 			} else if os.IsNotExist(err) {
 				// REBUILD ALL?
 				interestingEvent = true
@@ -60,7 +94,7 @@ func fileWatcher() {
 
 				for _, path := range dependencies[event.Name] {
 					wg.Add(1)
-					go buildPage(path, &wg)
+					go rebuildPage(path, &wg)
 				}
 			} else if event.Op&fsnotify.Create == fsnotify.Create && fileInfo.IsDir() {
 				// CREATE DIRECTORY PATH
@@ -72,12 +106,15 @@ func fileWatcher() {
 				if err != nil {
 					fmt.Println("Error building directories:", err)
 				}
-			} else if event.Op&fsnotify.Create == fsnotify.Create && strings.HasPrefix(event.Name, "src/assets") && !strings.HasSuffix(event.Name, ".DS_Store") {
+			} else if event.Op&fsnotify.Create == fsnotify.Create && inSrcDir(event.Name, "assets") && !strings.HasSuffix(event.Name, ".DS_Store") {
 				// CREATE ASSET
 				interestingEvent = true
 				wg.Add(1)
-				go buildPage(event.Name, &wg)
-			} else if event.Op&fsnotify.Create == fsnotify.Create && strings.HasPrefix(event.Name, "src/layouts") && !strings.HasSuffix(event.Name, ".DS_Store") {
+				go rebuildPage(event.Name, &wg)
+				if inBundle(event.Name) {
+					reportFailures(buildBundles())
+				}
+			} else if event.Op&fsnotify.Create == fsnotify.Create && inSrcDir(event.Name, "layouts") && !strings.HasSuffix(event.Name, ".DS_Store") {
 				// CREATE LAYOUT
 				interestingEvent = true
 				err = initializeDependencies()
@@ -87,9 +124,9 @@ func fileWatcher() {
 
 				for _, path := range dependencies[event.Name] {
 					wg.Add(1)
-					go buildPage(path, &wg)
+					go rebuildPage(path, &wg)
 				}
-			} else if event.Op&fsnotify.Create == fsnotify.Create && strings.HasPrefix(event.Name, "src/pages") && !strings.HasSuffix(event.Name, ".DS_Store") {
+			} else if event.Op&fsnotify.Create == fsnotify.Create && inSrcDir(event.Name, "pages") && !strings.HasSuffix(event.Name, ".DS_Store") {
 				// CREATE PAGE
 				interestingEvent = true
 				err = initializeDependencies()
@@ -97,16 +134,23 @@ func fileWatcher() {
 					log.Fatal("Error initializing dependencies:", err)
 				}
 
+				rebuildCollectionPages(event.Name, &wg)
+
 				wg.Add(1)
-				go buildPage(event.Name, &wg)
-			} else if event.Op&fsnotify.Create == fsnotify.Create && strings.HasPrefix(event.Name, "src/snippets") && !strings.HasSuffix(event.Name, ".DS_Store") {
-				// CREATE SNIPPET
+				go rebuildPage(event.Name, &wg)
+			} else if event.Op&fsnotify.Create == fsnotify.Create && (inSrcDir(event.Name, "snippets") || inSrcDir(event.Name, "shortcodes")) && !strings.HasSuffix(event.Name, ".DS_Store") {
+				// CREATE SNIPPET OR SHORTCODE
 				interestingEvent = true
 				err = initializeSnippets()
 				if err != nil {
 					log.Fatal("Error initializing dependencies:", err)
 				}
 
+				err = initializeShortcodes()
+				if err != nil {
+					log.Fatal("Error initializing shortcodes:", err)
+				}
+
 				err = initializeDependencies()
 				if err != nil {
 					log.Fatal("Error initializing dependencies:", err)
@@ -114,12 +158,11 @@ func fileWatcher() {
 
 				for _, path := range dependencies[event.Name] {
 					wg.Add(1)
-					go buildPage(path, &wg)
+					go rebuildPage(path, &wg)
 				}
-			} else if event.Op&fsnotify.Remove == fsnotify.Remove && strings.HasPrefix(event.Name, "src/assets") {
+			} else if event.Op&fsnotify.Remove == fsnotify.Remove && inSrcDir(event.Name, "assets") {
 				interestingEvent = true
-				distPath := event.Name
-				distPath = replaceAWithB(distPath, "src/", "dist/")
+				distPath := destPathFor(event.Name)
 				fmt.Println("Deleting from dist:", distPath)
 				_, err := os.Stat(distPath)
 				if err == nil {
@@ -128,10 +171,12 @@ func fileWatcher() {
 						fmt.Println("Error deleting:", distPath, err)
 					}
 				}
-			} else if event.Op&fsnotify.Rename == fsnotify.Rename && strings.HasPrefix(event.Name, "src/assets") {
+				if inBundle(event.Name) {
+					reportFailures(buildBundles())
+				}
+			} else if event.Op&fsnotify.Rename == fsnotify.Rename && inSrcDir(event.Name, "assets") {
 				interestingEvent = true
-				distPath := event.Name
-				distPath = replaceAWithB(distPath, "src/", "dist/")
+				distPath := destPathFor(event.Name)
 				fmt.Println("Deleting from dist:", distPath)
 				_, err := os.Stat(distPath)
 				if err == nil {
@@ -140,7 +185,10 @@ func fileWatcher() {
 						fmt.Println("Error deleting:", distPath, err)
 					}
 				}
-			} else if event.Op&fsnotify.Remove == fsnotify.Remove && strings.HasPrefix(event.Name, "src/layouts") {
+				if inBundle(event.Name) {
+					reportFailures(buildBundles())
+				}
+			} else if event.Op&fsnotify.Remove == fsnotify.Remove && inSrcDir(event.Name, "layouts") {
 				// DELETE LAYOUT
 				interestingEvent = true
 				err = initializeLayouts()
@@ -155,51 +203,52 @@ func fileWatcher() {
 
 				for _, path := range dependencies[event.Name] {
 					wg.Add(1)
-					go buildPage(path, &wg)
+					go rebuildPage(path, &wg)
 				}
-			} else if event.Op&fsnotify.Remove == fsnotify.Remove && strings.HasPrefix(event.Name, "src/pages") {
+			} else if event.Op&fsnotify.Remove == fsnotify.Remove && inSrcDir(event.Name, "pages") {
 				// DELETE PAGE
 				interestingEvent = true
+				distPath := builtPagePath(event.Name)
+
 				err = initializeDependencies()
 				if err != nil {
 					log.Fatal("Error initializing dependencies:", err)
 				}
 
-				distPath := event.Name
-				distPath = replaceAWithB(distPath, "src/", "dist/")
-				distPath = replaceAWithB(distPath, "pages/", "")
-				distPath = replaceAWithB(distPath, ".md", ".html")
-				fmt.Println("Deleting from dist:", distPath)
-				_, err := os.Stat(distPath)
-				if err == nil {
-					err := os.RemoveAll(distPath)
-					if err != nil {
-						fmt.Println("Error deleting:", distPath, err)
-					}
-				}
-			} else if event.Op&fsnotify.Remove == fsnotify.Remove && strings.HasPrefix(event.Name, "src/snippets") {
-				// DELETE SNIPPET
+				rebuildCollectionPages(event.Name, &wg)
+
+				removeOutput(distPath)
+			} else if event.Op&fsnotify.Remove == fsnotify.Remove && (inSrcDir(event.Name, "snippets") || inSrcDir(event.Name, "shortcodes")) {
+				// DELETE SNIPPET OR SHORTCODE
 				interestingEvent = true
 				err = initializeSnippets()
 				if err != nil {
 					log.Fatal("Error initializing snippets:", err)
 				}
 
+				err = initializeShortcodes()
+				if err != nil {
+					log.Fatal("Error initializing shortcodes:", err)
+				}
+
 				for _, path := range dependencies[event.Name] {
 					wg.Add(1)
-					go buildPage(path, &wg)
+					go rebuildPage(path, &wg)
 				}
 
 				err = initializeDependencies()
 				if err != nil {
 					log.Fatal("Error initializing dependencies:", err)
 				}
-			} else if event.Op&fsnotify.Write == fsnotify.Write && strings.HasPrefix(event.Name, "src/assets") {
+			} else if event.Op&fsnotify.Write == fsnotify.Write && inSrcDir(event.Name, "assets") {
 				// UPDATE ASSET
 				interestingEvent = true
 				wg.Add(1)
-				go buildPage(event.Name, &wg)
-			} else if event.Op&fsnotify.Write == fsnotify.Write && strings.HasPrefix(event.Name, "src/layouts") {
+				go rebuildPage(event.Name, &wg)
+				if inBundle(event.Name) {
+					reportFailures(buildBundles())
+				}
+			} else if event.Op&fsnotify.Write == fsnotify.Write && inSrcDir(event.Name, "layouts") {
 				// UPDATE LAYOUT
 				interestingEvent = true
 				err = initializeDependencies()
@@ -209,32 +258,45 @@ func fileWatcher() {
 
 				for _, path := range dependencies[event.Name] {
 					wg.Add(1)
-					go buildPage(path, &wg)
+					go rebuildPage(path, &wg)
 				}
-			} else if event.Op&fsnotify.Write == fsnotify.Write && strings.HasPrefix(event.Name, "src/pages") {
+			} else if event.Op&fsnotify.Write == fsnotify.Write && inSrcDir(event.Name, "pages") {
 				// UPDATE PAGE
 				interestingEvent = true
+				oldDistPath := builtPagePath(event.Name)
+
 				err = initializeDependencies()
 				if err != nil {
 					log.Fatal("Error initializing dependencies:", err)
 				}
 
+				rebuildCollectionPages(event.Name, &wg)
+
+				if distPath := builtPagePath(event.Name); distPath != oldDistPath {
+					removeOutput(oldDistPath)
+				}
+
 				wg.Add(1)
-				go buildPage(event.Name, &wg)
+				go rebuildPage(event.Name, &wg)
 
 				for _, path := range dependencies[event.Name] {
 					fmt.Println(path)
 					wg.Add(1)
-					go buildPage(path, &wg)
+					go rebuildPage(path, &wg)
 				}
-			} else if event.Op&fsnotify.Write == fsnotify.Write && strings.HasPrefix(event.Name, "src/snippets") {
-				// UPDATE SNIPPET
+			} else if event.Op&fsnotify.Write == fsnotify.Write && (inSrcDir(event.Name, "snippets") || inSrcDir(event.Name, "shortcodes")) {
+				// UPDATE SNIPPET OR SHORTCODE
 				interestingEvent = true
 				err := initializeSnippets()
 				if err != nil {
 					log.Fatal("Error initializing snippets:", err)
 				}
 
+				err = initializeShortcodes()
+				if err != nil {
+					log.Fatal("Error initializing shortcodes:", err)
+				}
+
 				err = initializeDependencies()
 				if err != nil {
 					log.Fatal("Error initializing dependencies:", err)
@@ -242,7 +304,7 @@ func fileWatcher() {
 
 				for _, path := range dependencies[event.Name] {
 					wg.Add(1)
-					go buildPage(path, &wg)
+					go rebuildPage(path, &wg)
 				}
 			}
 
@@ -260,6 +322,81 @@ func fileWatcher() {
 	}
 }
 
+// rebuildCollectionPages refreshes collections and taxonomies after a page
+// changes and rebuilds the pages that list the page's collection, the term
+// pages the page was or is now in, and, if its terms changed, the pages that
+// list terms.
+func rebuildCollectionPages(path string, wg *sync.WaitGroup) {
+	oldTerms := pageTerms(path)
+
+	err := initializeCollections()
+	if err != nil {
+		fmt.Println("Error initializing collections:", err)
+		return
+	}
+
+	err = initializeTaxonomies()
+	if err != nil {
+		fmt.Println("Error initializing taxonomies:", err)
+		return
+	}
+
+	newTerms := pageTerms(path)
+
+	for _, dependent := range dependencies[filepath.Dir(path)] {
+		if dependent == path {
+			continue
+		}
+		wg.Add(1)
+		go rebuildPage(dependent, wg)
+	}
+
+	if multilingual() {
+		for _, dependent := range dependencies[TRANSLATIONS_DEPENDENCY_PREFIX+translationKey(path)] {
+			if dependent == path {
+				continue
+			}
+			wg.Add(1)
+			go rebuildPage(dependent, wg)
+		}
+	}
+
+	if name := collectionName(path); name != "" {
+		reportFailures(buildCollectionFeeds(name))
+	}
+	reportFailures(buildSitemap())
+
+	err = initializeRedirects()
+	if err != nil {
+		fmt.Println("Error initializing redirects:", err)
+	} else {
+		reportFailures(buildRedirects())
+	}
+
+	terms := oldTerms
+	termsChanged := len(oldTerms) != len(newTerms)
+	for _, term := range newTerms {
+		if !sliceContains(term, oldTerms) {
+			termsChanged = true
+			terms = append(terms, term)
+		}
+	}
+
+	if len(terms) > 0 {
+		reportFailures(buildTaxonomyPages(terms))
+	}
+
+	if termsChanged {
+		for _, dependent := range dependencies[TAXONOMIES_DEPENDENCY] {
+			if dependent == path {
+				continue
+			}
+			wg.Add(1)
+			go rebuildPage(dependent, wg)
+		}
+	}
+}
+
 func hotReloadHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Println("Client connected")
 	messageChan := make(chan string)
@@ -295,6 +432,23 @@ func hotReloadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// servesFile reports whether the dev server has a file for urlPath.
+func servesFile(urlPath string) bool {
+	candidates := []string{urlPath, strings.TrimSuffix(urlPath, "/") + "/index.html"}
+	for _, candidate := range candidates {
+		file, err := http.Dir(config.Output).Open(candidate)
+		if err != nil {
+			continue
+		}
+		info, err := file.Stat()
+		file.Close()
+		if err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
 func requestHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		http.Error(w, "Method is not supported", http.StatusNotFound)
@@ -303,16 +457,33 @@ func requestHandler(w http.ResponseWriter, r *http.Request) {
 
 	path := r.URL.Path
 
+	// like Netlify, only forced redirects apply when there is a file
+	if redirect, found := findRedirect(path); found && (redirect.Force || !servesFile(path)) {
+		http.Redirect(w, r, redirect.To, redirect.Status)
+		return
+	}
+
 	if strings.HasSuffix(path, "/") {
 		// Look for /index.html
-		_, err := http.Dir(DIST).Open(path + "index.html")
+		_, err := http.Dir(config.Output).Open(path + "index.html")
 		if err == nil {
 			path += "index.html"
 		}
+	} else if _, err := http.Dir(config.Output).Open(path + "/index.html"); err == nil {
+		// Pretty URLs live at /about/
+		http.Redirect(w, r, path+"/", http.StatusMovedPermanently)
+		return
 	}
 
 	// Check if file exists
-	contentBytes, err := os.ReadFile(DIST + path)
+	file, err := http.Dir(config.Output).Open(path)
+	if err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	contentBytes, err := io.ReadAll(file)
 	if err != nil {
 		http.Error(w, "Not found", http.StatusNotFound)
 		return
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"hash"
+	"html"
+	"html/template"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A site in more than one language lists them in sssg.yaml. The default is
+// `language`, or else the first one:
+//
+// language: en
+// languages: [en, de]
+//
+// A page is in a language when its file name says so, about.de.md, or when
+// it is in a directory named after the language, src/pages/de/about.md. Both
+// are built to /de/about.html. Everything else is in the default language
+// and is built as before.
+//
+// Pages with the same path apart from the language are translations of each
+// other. Templates get the page's .Language, its .Translations for a language
+// switcher and .Strings, the string table for its language from
+// src/data/i18n/de.yaml:
+//
+// {{range .Translations}}<a href="{{.Path}}" hreflang="{{.Language}}">{{.Language}}</a>{{end}}
+// <a href="/blog/">{{.Strings.blog}}</a>
+//
+// A page with translations gets a <link rel="alternate" hreflang="..."> for
+// each of them in its <head>, unless its layout puts {{.HreflangLinks}}
+// somewhere itself. On a German page .Site.Collections.blog lists the German
+// posts and .Site.Language is "de".
+
+const TRANSLATIONS_DEPENDENCY_PREFIX = "translations:"
+const STRINGS_DATA_KEY = "i18n"
+
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+var headCloseTagPattern = regexp.MustCompile(`(?i)</head>`)
+
+var translations = make(map[string][]Page)
+
+func multilingual() bool {
+	return len(config.Languages) > 0
+}
+
+func defaultLanguage() string {
+	if config.Language != "" || !multilingual() {
+		return config.Language
+	}
+	return config.Languages[0]
+}
+
+func validateLanguages(cfg Config) error {
+	for _, language := range cfg.Languages {
+		if !languagePattern.MatchString(language) {
+			return fmt.Errorf("languages: %q is not a language code like en or pt-BR", language)
+		}
+	}
+	if cfg.Language != "" && len(cfg.Languages) > 0 && !sliceContains(cfg.Language, cfg.Languages) {
+		return fmt.Errorf("language: %q is not one of languages %v", cfg.Language, cfg.Languages)
+	}
+	return nil
+}
+
+// languageSourcePath returns the language of the page at srcPath and where
+// the page would be if it were in its language's directory, so
+// src/pages/blog/post.de.md is "de" and src/pages/de/blog/post.md.
+func languageSourcePath(srcPath string) (string, string) {
+	if !multilingual() {
+		return defaultLanguage(), srcPath
+	}
+
+	pagesDir := filepath.Join(config.Source, "pages")
+	rel, err := filepath.Rel(pagesDir, srcPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return defaultLanguage(), srcPath
+	}
+
+	ext := filepath.Ext(rel)
+	stem := strings.TrimSuffix(rel, ext)
+	if suffix := filepath.Ext(stem); suffix != "" && sliceContains(suffix[1:], config.Languages) {
+		language := suffix[1:]
+		rel = strings.TrimSuffix(stem, suffix) + ext
+		if language != defaultLanguage() {
+			rel = filepath.Join(language, rel)
+		}
+		return language, filepath.Join(pagesDir, rel)
+	}
+
+	first, _, nested := strings.Cut(filepath.ToSlash(rel), "/")
+	if nested && sliceContains(first, config.Languages) {
+		return first, srcPath
+	}
+	return defaultLanguage(), srcPath
+}
+
+// translationKey is the same for every language's version of a page.
+func translationKey(srcPath string) string {
+	language, srcPath := languageSourcePath(srcPath)
+	rel, err := filepath.Rel(filepath.Join(config.Source, "pages"), srcPath)
+	if err != nil {
+		return srcPath
+	}
+	rel = filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel)))
+	if language != defaultLanguage() {
+		rel = strings.TrimPrefix(rel, language+"/")
+	}
+	return rel
+}
+
+// unlocalizedName turns the collection "de/blog" into "blog" for a German
+// page.
+func unlocalizedName(name string, language string) string {
+	if language == defaultLanguage() {
+		return name
+	}
+	return strings.TrimPrefix(name, language+"/")
+}
+
+// collectionDirs are the directories a page in language can get the pages of
+// a collection from. German posts can be in src/pages/de/blog or next to the
+// others as src/pages/blog/post.de.md.
+func collectionDirs(name string, language string) []string {
+	dirs := []string{filepath.Join(config.Source, "pages", name)}
+	if language != defaultLanguage() {
+		dirs = append(dirs, filepath.Join(config.Source, "pages", language, name))
+	}
+	return dirs
+}
+
+// localizePermalink puts a page that isn't in the default language under
+// its language's directory.
+func localizePermalink(permalink string, language string) string {
+	if language == defaultLanguage() || strings.HasPrefix(permalink, "/"+language+"/") {
+		return permalink
+	}
+	return "/" + language + permalink
+}
+
+func initializeTranslations() {
+	translations = make(map[string][]Page)
+	if !multilingual() {
+		return
+	}
+
+	for _, page := range site.Pages {
+		key := translationKey(page.SourcePath)
+		translations[key] = append(translations[key], page)
+	}
+}
+
+// writeTranslationsHash covers the other languages' versions of a page, which
+// are in its language switcher and hreflang links.
+func writeTranslationsHash(h hash.Hash, key string) {
+	for _, page := range translations[key] {
+		fmt.Fprintf(h, "%s %s %s %s\n", page.Language, page.SourcePath, page.Path, page.Title)
+	}
+}
+
+// addTranslations fills in the language fields of a page.
+func addTranslations(page *Page) {
+	language, _ := languageSourcePath(page.SourcePath)
+	page.Language = language
+	if !multilingual() {
+		return
+	}
+
+	page.Site.Languages = config.Languages
+	page.Site.Language = language
+	page.Strings = languageStrings(language)
+
+	if language != defaultLanguage() {
+		localized := make(map[string][]Page)
+		for name, pages := range collections {
+			if unlocalized := unlocalizedName(name, language); unlocalized != name {
+				localized[unlocalized] = pages
+			}
+		}
+		page.Site.Collections = localized
+	}
+
+	if page.SourcePath == "" {
+		return
+	}
+
+	for _, other := range config.Languages {
+		for _, translation := range translations[translationKey(page.SourcePath)] {
+			if translation.SourcePath != page.SourcePath && translation.Language == other {
+				page.Translations = append(page.Translations, translation)
+			}
+		}
+	}
+	page.HreflangLinks = hreflangLinks(*page)
+}
+
+// stringsDataFiles are the data files .Strings comes from for a page in
+// language.
+func stringsDataFiles(language string) []DataFile {
+	var files []DataFile
+	key := STRINGS_DATA_KEY + "." + language
+	for _, file := range dataFiles {
+		if file.Key == STRINGS_DATA_KEY || file.Key == key || strings.HasPrefix(file.Key, key+".") {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+func languageStrings(language string) map[string]interface{} {
+	tables, _ := site.Data[STRINGS_DATA_KEY].(map[string]interface{})
+	table, _ := tables[language].(map[string]interface{})
+	return table
+}
+
+func hreflangLinks(page Page) template.HTML {
+	if len(page.Translations) == 0 {
+		return ""
+	}
+
+	versions := append([]Page{page}, page.Translations...)
+	var links strings.Builder
+	for _, version := range versions {
+		fmt.Fprintf(&links, "<link rel=\"alternate\" hreflang=\"%s\" href=\"%s\">\n", version.Language, html.EscapeString(version.URL))
+	}
+	for _, version := range versions {
+		if version.Language == defaultLanguage() {
+			fmt.Fprintf(&links, "<link rel=\"alternate\" hreflang=\"x-default\" href=\"%s\">\n", html.EscapeString(version.URL))
+		}
+	}
+	return template.HTML(links.String())
+}
+
+// addHreflangLinks puts the page's hreflang links at the end of its <head>
+// when the layout didn't.
+func addHreflangLinks(rendered []byte, page Page) []byte {
+	if page.HreflangLinks == "" || strings.Contains(string(rendered), "hreflang=") {
+		return rendered
+	}
+
+	location := headCloseTagPattern.FindIndex(rendered)
+	if location == nil {
+		return rendered
+	}
+
+	var withLinks []byte
+	withLinks = append(withLinks, rendered[:location[0]]...)
+	withLinks = append(withLinks, page.HreflangLinks...)
+	return append(withLinks, rendered[location[0]:]...)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A layout can be wrapped in a parent layout the same way a page is, either
+// with front matter:
+//
+// ---
+// layout: Default
+// ---
+// <article>__CONTENT__</article>
+//
+// or with a <DefaultLayout>...</DefaultLayout> wrapper. wrapHtmlInLayout
+// renders the page's layout and then each parent in turn.
+//
+// Layouts are templates. A layout with `template: false` in its front matter
+// only has __CONTENT__ replaced, so it can contain {{ }} for a client-side
+// framework.
+
+type layoutStep struct {
+	Layout   Layout
+	Body     string
+	Template bool
+}
+
+func defaultLayout() Layout {
+	layout, found := findLayout(config.DefaultLayout)
+	if found {
+		return layout
+	}
+	return Layout{
+		Name: config.DefaultLayout,
+		Path: filepath.Join(config.Source, "layouts", config.DefaultLayout+".html"),
+	}
+}
+
+func findLayout(name string) (Layout, bool) {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if name == "" {
+		return Layout{}, false
+	}
+	for _, layout := range layouts {
+		if strings.EqualFold(layout.Name, name) {
+			return layout, true
+		}
+	}
+	return Layout{}, false
+}
+
+// unwrapLayoutTags looks for content wrapped in <NameLayout></NameLayout>
+// and returns the named layout along with the content minus the tags.
+func unwrapLayoutTags(content string) (Layout, string, bool) {
+	for _, layout := range layouts {
+		openTag := "<" + layout.Name + "Layout>"
+		closeTag := "</" + layout.Name + "Layout>"
+		if strings.HasPrefix(strings.TrimSpace(content), openTag) && strings.Contains(content, closeTag) {
+			unwrapped := replaceAWithB(content, openTag, "")
+			unwrapped = replaceAWithB(unwrapped, closeTag, "")
+			return layout, unwrapped, true
+		}
+	}
+	return Layout{}, content, false
+}
+
+// pageLayout picks the layout for a page from its front matter, then from a
+// layout wrapper, and otherwise falls back to the default layout. A layout
+// in the front matter that doesn't exist is an error, and the page gets the
+// fallback along with it.
+func pageLayout(content string, frontMatter FrontMatter) (Layout, string, error) {
+	var err error
+	if frontMatter.Layout != "" {
+		layout, found := findLayout(frontMatter.Layout)
+		if found {
+			return layout, content, nil
+		}
+		err = fmt.Errorf("layout not found: %s", frontMatter.Layout)
+	}
+
+	layout, unwrapped, found := unwrapLayoutTags(content)
+	if found {
+		return layout, unwrapped, err
+	}
+
+	unwrapped = replaceAWithB(content, "<DefaultLayout>", "")
+	unwrapped = replaceAWithB(unwrapped, "</DefaultLayout>", "")
+	return defaultLayout(), unwrapped, err
+}
+
+// layoutChain returns the layout followed by each of its parents, innermost
+// first.
+func layoutChain(layout Layout) ([]layoutStep, error) {
+	var chain []layoutStep
+	var names []string
+
+	for {
+		for _, name := range names {
+			if name == layout.Name {
+				return nil, fmt.Errorf("layout cycle: %s -> %s", strings.Join(names, " -> "), layout.Name)
+			}
+		}
+		names = append(names, layout.Name)
+
+		data, err := os.ReadFile(layout.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		frontMatter, body, err := parseFrontMatter(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", layout.Path, err)
+		}
+
+		var parent Layout
+		hasParent := false
+		if frontMatter.Layout != "" {
+			parent, hasParent = findLayout(frontMatter.Layout)
+			if !hasParent {
+				return nil, fmt.Errorf("%s: parent layout not found: %s", layout.Path, frontMatter.Layout)
+			}
+		} else {
+			var unwrapped string
+			parent, unwrapped, hasParent = unwrapLayoutTags(string(body))
+			if hasParent {
+				body = []byte(unwrapped)
+			}
+		}
+
+		template := frontMatter.Template == nil || *frontMatter.Template
+		chain = append(chain, layoutStep{Layout: layout, Body: string(body), Template: template})
+
+		if !hasParent {
+			return chain, nil
+		}
+		layout = parent
+	}
+}
+
+func addDependency(dependency string, path string) {
+	if !sliceContains(path, dependencies[dependency]) {
+		dependencies[dependency] = append(dependencies[dependency], path)
+	}
+}
@@ -2,13 +2,17 @@ package main
 
 import (
 	"embed"
+	"errors"
 	"flag"
 	"fmt"
+	"html/template"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -22,19 +26,70 @@ type Layout struct {
 }
 
 type Snippet struct {
-	Name string
-	Path string
+	Name    string
+	Path    string
+	Pattern *regexp.Regexp
 }
 
 type FrontMatter struct {
-	Layout string `yaml:"layout"`
+	Title       string                 `yaml:"title"`
+	Layout      string                 `yaml:"layout"`
+	Description string                 `yaml:"description"`
+	Date        time.Time              `yaml:"date"`
+	Draft       bool                   `yaml:"draft"`
+	PublishDate time.Time              `yaml:"publishDate"`
+	ExpiryDate  time.Time              `yaml:"expiryDate"`
+	Tags        []string               `yaml:"tags"`
+	Categories  []string               `yaml:"categories"`
+	Permalink   string                 `yaml:"permalink"`
+	Aliases     []string               `yaml:"aliases"`
+	Paginate    string                 `yaml:"paginate"`
+	PerPage     int                    `yaml:"perPage"`
+	Sitemap     *bool                  `yaml:"sitemap"`
+	TOC         *bool                  `yaml:"toc"`
+	TOCDepth    int                    `yaml:"tocDepth"`
+	Template    *bool                  `yaml:"template"`
+	Params      map[string]interface{} `yaml:",inline"`
 }
 
-var PORT = "8080"
+type Page struct {
+	Content         template.HTML
+	TableOfContents template.HTML
+	TOC             []*TOCEntry
+	Title           string
+	Description     string
+	Summary         string
+	Date            time.Time
+	Tags            []string
+	Categories      []string
+	Params          map[string]interface{}
+	FrontMatter     FrontMatter
+	SourcePath      string
+	Path            string
+	URL             string
+	Paginator       *Paginator
+	Term            *Term
+	Terms           []Term
+	Language        string
+	Translations    []Page
+	Strings         map[string]interface{}
+	HreflangLinks   template.HTML
+	Site            Site
+	BuildTime       time.Time
+}
 
-const DIST = "dist"
-const SRC = "./src"
-const DEFAULT_LAYOUT = "src/layouts/Default.html"
+type Site struct {
+	Title       string
+	BaseURL     string
+	Author      string
+	Language    string
+	Languages   []string
+	Params      map[string]interface{}
+	Pages       []Page
+	Collections map[string][]Page
+	Taxonomies  map[string][]Term
+	Data        map[string]interface{}
+}
 
 // src
 // └── assets
@@ -64,6 +119,8 @@ var clients = make(map[chan string]bool)
 var layouts []Layout
 var snippets []Snippet
 var dependencies = make(map[string][]string)
+var site Site
+var buildTime time.Time
 
 func main() {
 	var doBuild bool
@@ -81,6 +138,11 @@ func main() {
 	var env string
 	envOptions := []string{"production", "staging"}
 	flag.StringVar(&env, "env", envOptions[1], fmt.Sprintf("one of %v, defaults to %v", envOptions, envOptions[1]))
+	flag.BoolVar(&includeDrafts, "drafts", false, "build pages with draft: true")
+	flag.BoolVar(&includeFuture, "future", false, "build pages with a publishDate in the future")
+	flag.BoolVar(&cleanBuild, "clean", false, "ignore the build cache and rebuild everything")
+	flag.BoolVar(&keepGoing, "keep-going", false, "write the pages that built even when others fail")
+	flag.BoolVar(&minifyFlag, "minify", false, "minify pages and assets, in dev too")
 	flag.Parse()
 
 	err := godotenv.Load(".env")
@@ -90,6 +152,11 @@ func main() {
 			log.Fatalf("Could not load or create .env file: %s", err)
 		}
 	}
+
+	config, err = loadConfig()
+	if err != nil {
+		log.Fatalf("Invalid config: %s", err)
+	}
 	if doBuild {
 		err := build(false)
 		if err != nil {
@@ -107,20 +174,31 @@ func main() {
 			log.Fatalf("Init failed: %s", err)
 		}
 	} else if doDev {
+		includeDrafts = true
+		includeFuture = true
+		includeExpired = true
+		showStatusBanner = true
+		useBuildCache = false
+		useFingerprints = false
+		useMinify = false
+		keepGoing = true
 		err := build(false)
-		if err != nil {
+		var buildErr *BuildError
+		if errors.As(err, &buildErr) {
+			fmt.Println("Error:", err)
+		} else if err != nil {
 			log.Fatalf("Could not build: %s", err)
 		}
 		go fileWatcher()
 		http.HandleFunc("/", requestHandler)
 		http.HandleFunc("/sssg-hot-reload", hotReloadHandler)
-		ln, err := net.Listen("tcp", ":"+PORT)
+		ln, err := net.Listen("tcp", ":"+fmt.Sprint(config.Port))
 		if err != nil {
 			if strings.Contains(err.Error(), "address already in use") {
-				for port := 8080; port < 65535; port++ {
+				for port := config.Port + 1; port < 65535; port++ {
 					ln, err = net.Listen("tcp", ":"+fmt.Sprint(port))
 					if err == nil {
-						PORT = fmt.Sprint(port)
+						config.Port = port
 						break
 					}
 				}
@@ -128,7 +206,7 @@ func main() {
 				log.Fatal(err)
 			}
 		}
-		fmt.Printf("Server started on port %v\n", PORT)
+		fmt.Printf("Server started on port %v\n", config.Port)
 		log.Fatal(http.Serve(ln, nil))
 	} else {
 		flag.Usage()
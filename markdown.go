@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/util"
+)
+
+// Markdown pages are rendered with goldmark as GitHub Flavored Markdown:
+// tables, ~~strikethrough~~, task lists (- [x] done) and bare links like
+// https://example.com. Footnotes[^1] and definition lists work too, and
+// headings get an id and an anchor link. Write `## Install {#setup}` to pick
+// the id yourself. Raw HTML, and so snippets, pass straight through. Every
+// feature can be turned off in sssg.yaml:
+//
+// markdown:
+//   tables: true
+//   strikethrough: true
+//   taskLists: true
+//   autolinks: true
+//   footnotes: true
+//   definitionLists: true
+//   headingIDs: true
+//   headingAnchors: true
+
+type MarkdownConfig struct {
+	Tables          bool `yaml:"tables" toml:"tables"`
+	Strikethrough   bool `yaml:"strikethrough" toml:"strikethrough"`
+	TaskLists       bool `yaml:"taskLists" toml:"taskLists"`
+	Autolinks       bool `yaml:"autolinks" toml:"autolinks"`
+	Footnotes       bool `yaml:"footnotes" toml:"footnotes"`
+	DefinitionLists bool `yaml:"definitionLists" toml:"definitionLists"`
+	HeadingIDs      bool `yaml:"headingIDs" toml:"headingIDs"`
+	HeadingAnchors  bool `yaml:"headingAnchors" toml:"headingAnchors"`
+}
+
+var markdownKeys = []string{"tables", "strikethrough", "taskLists", "autolinks", "footnotes", "definitionLists", "headingIDs", "headingAnchors"}
+
+var markdown goldmark.Markdown
+
+func defaultMarkdownConfig() MarkdownConfig {
+	return MarkdownConfig{
+		Tables:          true,
+		Strikethrough:   true,
+		TaskLists:       true,
+		Autolinks:       true,
+		Footnotes:       true,
+		DefinitionLists: true,
+		HeadingIDs:      true,
+		HeadingAnchors:  true,
+	}
+}
+
+func initializeMarkdown() {
+	options := config.Markdown
+
+	var extensions []goldmark.Extender
+	if options.Tables {
+		extensions = append(extensions, extension.Table)
+	}
+	if options.Strikethrough {
+		extensions = append(extensions, extension.Strikethrough)
+	}
+	if options.TaskLists {
+		extensions = append(extensions, extension.TaskList)
+	}
+	if options.Autolinks {
+		extensions = append(extensions, extension.Linkify)
+	}
+	if options.Footnotes {
+		extensions = append(extensions, extension.Footnote)
+	}
+	if options.DefinitionLists {
+		extensions = append(extensions, extension.DefinitionList)
+	}
+
+	parserOptions := []parser.Option{parser.WithAttribute()}
+	if options.HeadingIDs {
+		parserOptions = append(parserOptions, parser.WithAutoHeadingID())
+	}
+
+	rendererOptions := []renderer.Option{html.WithUnsafe()}
+	if options.HeadingAnchors {
+		rendererOptions = append(rendererOptions, renderer.WithNodeRenderers(util.Prioritized(&headingAnchorRenderer{}, 100)))
+	}
+	if config.Highlight.Enabled {
+		rendererOptions = append(rendererOptions, renderer.WithNodeRenderers(util.Prioritized(&codeBlockRenderer{}, 100)))
+	}
+
+	markdown = goldmark.New(
+		goldmark.WithExtensions(extensions...),
+		goldmark.WithParserOptions(parserOptions...),
+		goldmark.WithRendererOptions(rendererOptions...),
+	)
+}
+
+func renderMarkdown(source []byte) ([]byte, error) {
+	var rendered bytes.Buffer
+	err := markdown.Convert(source, &rendered)
+	if err != nil {
+		return source, err
+	}
+	return rendered.Bytes(), nil
+}
+
+// headingAnchorRenderer renders headings that have an id with a link to
+// themselves: <h2 id="install">Install <a class="anchor" href="#install">#</a></h2>
+type headingAnchorRenderer struct{}
+
+func (r *headingAnchorRenderer) RegisterFuncs(registerer renderer.NodeRendererFuncRegisterer) {
+	registerer.Register(ast.KindHeading, r.renderHeading)
+}
+
+func (r *headingAnchorRenderer) renderHeading(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	heading := node.(*ast.Heading)
+	if entering {
+		fmt.Fprintf(w, "<h%d", heading.Level)
+		if heading.Attributes() != nil {
+			html.RenderAttributes(w, heading, html.HeadingAttributeFilter)
+		}
+		w.WriteByte('>')
+		return ast.WalkContinue, nil
+	}
+
+	if id, found := heading.AttributeString("id"); found {
+		if idBytes, ok := id.([]byte); ok {
+			fmt.Fprintf(w, ` <a class="anchor" href="#%s" aria-hidden="true">#</a>`, util.EscapeHTML(idBytes))
+		}
+	}
+	fmt.Fprintf(w, "</h%d>\n", heading.Level)
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The samples in testdata/markdown are rendered and compared with the
+// .golden.html next to them. go test -run Golden -update rewrites the golden
+// files after a deliberate change to the output.
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestMarkdownGolden(t *testing.T) {
+	config = defaultConfig()
+	config.Markdown = defaultMarkdownConfig()
+	initializeMarkdown()
+
+	samples, err := filepath.Glob(filepath.Join("testdata", "markdown", "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) == 0 {
+		t.Fatal("no samples in testdata/markdown")
+	}
+
+	for _, sample := range samples {
+		t.Run(filepath.Base(sample), func(t *testing.T) {
+			source, err := os.ReadFile(sample)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rendered, err := renderMarkdown(source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			goldenPath := strings.TrimSuffix(sample, ".md") + ".golden.html"
+			if *updateGolden {
+				err = os.WriteFile(goldenPath, rendered, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			golden, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(rendered) != string(golden) {
+				t.Errorf("%s renders as\n%s\nwant\n%s", sample, rendered, golden)
+			}
+		})
+	}
+}
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// With `minify: true` in sssg.yaml, sssg build minifies the HTML, CSS, JS,
+// SVG and JSON it writes, pages and assets alike, and prints how much
+// smaller each file got. Files named like pico.min.css are already minified
+// and are left alone. sssg dev doesn't minify unless it is run with -minify,
+// which also turns minifying on for a build without the setting.
+//
+// The minifiers only take out comments and whitespace, so they never
+// change what a page does. Comments starting with /*! are kept, as are
+// <pre>, <textarea> and conditional comments in HTML.
+
+var minifyFlag bool
+var useMinify = true
+
+var minifiedFiles int
+var minifiedBefore int
+var minifiedAfter int
+var minifyMutex sync.Mutex
+
+var blockTags = map[string]bool{
+	"html": true, "head": true, "body": true, "title": true, "meta": true, "link": true, "script": true, "style": true, "noscript": true,
+	"header": true, "footer": true, "main": true, "nav": true, "section": true, "article": true, "aside": true, "div": true, "p": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "hr": true, "br": true, "pre": true, "blockquote": true,
+	"ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true, "figure": true, "figcaption": true, "form": true, "fieldset": true,
+	"table": true, "caption": true, "thead": true, "tbody": true, "tfoot": true, "tr": true, "th": true, "td": true, "details": true, "summary": true,
+}
+
+var jsKeywordsBeforeRegex = []string{"return", "typeof", "instanceof", "in", "of", "new", "delete", "void", "throw", "case", "do", "else", "yield", "await"}
+
+func minifying() bool {
+	return minifyFlag || (config.Minify && useMinify)
+}
+
+func resetMinifyStats() {
+	minifyMutex.Lock()
+	defer minifyMutex.Unlock()
+
+	minifiedFiles = 0
+	minifiedBefore = 0
+	minifiedAfter = 0
+}
+
+// minifyOutput minifies data for the type of file at destPath and returns
+// it with a note of how much smaller it got, for the build log.
+func minifyOutput(destPath string, data []byte) ([]byte, string, error) {
+	if !minifying() || strings.Contains(filepath.Base(destPath), ".min.") {
+		return data, "", nil
+	}
+
+	minified, err := minify(filepath.Ext(destPath), data)
+	if err != nil || len(minified) >= len(data) {
+		return data, "", err
+	}
+
+	minifyMutex.Lock()
+	defer minifyMutex.Unlock()
+
+	minifiedFiles++
+	minifiedBefore += len(data)
+	minifiedAfter += len(minified)
+	return minified, fmt.Sprintf(" (%s -> %s, %s)", formatSize(len(data)), formatSize(len(minified)), percentSmaller(len(data), len(minified))), nil
+}
+
+func minify(ext string, data []byte) ([]byte, error) {
+	switch strings.ToLower(ext) {
+	case ".html", ".htm":
+		return minifyHTML(data), nil
+	case ".css":
+		return minifyCSS(data), nil
+	case ".js", ".mjs":
+		return minifyJS(data), nil
+	case ".svg":
+		return minifySVG(data), nil
+	case ".json", ".webmanifest":
+		var compacted bytes.Buffer
+		err := json.Compact(&compacted, data)
+		return compacted.Bytes(), err
+	}
+	return data, nil
+}
+
+func printMinifySummary() {
+	if minifiedFiles == 0 {
+		return
+	}
+	fmt.Printf("Minified %s from %s to %s, %s\n", plural(minifiedFiles, "file"), formatSize(minifiedBefore), formatSize(minifiedAfter), percentSmaller(minifiedBefore, minifiedAfter))
+}
+
+func formatSize(size int) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%d B", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+}
+
+func percentSmaller(before int, after int) string {
+	if before == 0 {
+		return "0% smaller"
+	}
+	return fmt.Sprintf("%d%% smaller", (before-after)*100/before)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func lastByte(out *bytes.Buffer) byte {
+	if out.Len() == 0 {
+		return 0
+	}
+	return out.Bytes()[out.Len()-1]
+}
+
+// quotedEnd returns the index just past the string starting at data[i]. A
+// string that runs into the end of the line stops there.
+func quotedEnd(data []byte, i int) int {
+	quote := data[i]
+	for j := i + 1; j < len(data); j++ {
+		switch data[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		case '\n':
+			return j
+		}
+	}
+	return len(data)
+}
+
+func minifyCSS(data []byte) []byte {
+	var out bytes.Buffer
+	space := false
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(data)
+			} else {
+				end += i + 4
+			}
+			if bytes.HasPrefix(data[i:], []byte("/*!")) {
+				out.Write(data[i:end])
+			} else {
+				space = true
+			}
+			i = end - 1
+		case isSpace(c):
+			space = true
+		default:
+			if space && out.Len() > 0 && !strings.ContainsRune("{};,:>~(", rune(lastByte(&out))) && !strings.ContainsRune("{};,>~)!", rune(c)) && !(c == ':' && !inCSSSelector(data[i:])) {
+				out.WriteByte(' ')
+			}
+			space = false
+
+			if c == '}' && lastByte(&out) == ';' {
+				out.Truncate(out.Len() - 1)
+			}
+			if c == '"' || c == '\'' {
+				end := quotedEnd(data, i)
+				out.Write(data[i:end])
+				i = end - 1
+				continue
+			}
+			out.WriteByte(c)
+		}
+	}
+	return bytes.TrimSpace(out.Bytes())
+}
+
+// inCSSSelector reports whether the CSS in rest is in a selector, where a
+// space before a colon matters, rather than in a declaration. A selector
+// is the one followed by a block.
+func inCSSSelector(rest []byte) bool {
+	end := bytes.IndexAny(rest, "{;}")
+	return end >= 0 && rest[end] == '{'
+}
+
+func isJSIdentifier(c byte) bool {
+	return c == '_' || c == '$' || c == '\\' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// jsNeedsSpace reports whether the whitespace between prev and next keeps
+// two tokens apart, like `return x` or `a + +b`.
+func jsNeedsSpace(prev byte, next byte) bool {
+	switch {
+	case isJSIdentifier(prev) && isJSIdentifier(next):
+		return true
+	case (prev == '+' || prev == '-') && prev == next:
+		return true
+	case prev == '/' && (next == '/' || next == '*'):
+		return true
+	case prev >= '0' && prev <= '9' && next == '.':
+		return true
+	}
+	return false
+}
+
+// jsNeedsNewline reports whether a line break between the code in out and
+// next might end a statement, which a space wouldn't. x++ and x-- can end
+// one.
+func jsNeedsNewline(out []byte, next byte) bool {
+	prev := out[len(out)-1]
+	if (prev == '+' || prev == '-') && len(out) > 1 && out[len(out)-2] == prev {
+		return true
+	}
+	return !strings.ContainsRune("{;,([=:?&|<>+-*%!~^", rune(prev)) && !strings.ContainsRune("})],;.?:=", rune(next))
+}
+
+// jsRegexAllowed reports whether a / after the code in out starts a regular
+// expression rather than dividing. Guessing wrong the other way is harmless,
+// since a regular expression is copied as it is.
+func jsRegexAllowed(out []byte) bool {
+	code := bytes.TrimRight(out, " \n")
+	if len(code) == 0 {
+		return true
+	}
+
+	last := code[len(code)-1]
+	if last == ')' || last == ']' {
+		return false
+	}
+	if !isJSIdentifier(last) {
+		return true
+	}
+
+	start := len(code)
+	for start > 0 && isJSIdentifier(code[start-1]) {
+		start--
+	}
+	return sliceContains(string(code[start:]), jsKeywordsBeforeRegex)
+}
+
+func jsRegexEnd(data []byte, i int) int {
+	inClass := false
+	for j := i + 1; j < len(data); j++ {
+		switch data[j] {
+		case '\\':
+			j++
+		case '\n':
+			return j
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '/':
+			if !inClass {
+				j++
+				for j < len(data) && isJSIdentifier(data[j]) {
+					j++
+				}
+				return j
+			}
+		}
+	}
+	return len(data)
+}
+
+// jsTemplateEnd returns the index just past the template literal starting at
+// data[i], including any ${} inside it.
+func jsTemplateEnd(data []byte, i int) int {
+	for j := i + 1; j < len(data); j++ {
+		switch {
+		case data[j] == '\\':
+			j++
+		case data[j] == '`':
+			return j + 1
+		case data[j] == '$' && j+1 < len(data) && data[j+1] == '{':
+			j = jsExpressionEnd(data, j+2) - 1
+		}
+	}
+	return len(data)
+}
+
+func jsExpressionEnd(data []byte, i int) int {
+	depth := 1
+	for j := i; j < len(data); j++ {
+		switch data[j] {
+		case '"', '\'':
+			j = quotedEnd(data, j) - 1
+		case '`':
+			j = jsTemplateEnd(data, j) - 1
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return len(data)
+}
+
+func minifyJS(data []byte) []byte {
+	var out bytes.Buffer
+	space := false
+	newline := false
+
+	// writeToken writes what comes after some whitespace with a line break
+	// or a space in between when they are needed.
+	writeToken := func(token []byte) {
+		if space && out.Len() > 0 {
+			prev := lastByte(&out)
+			if newline && jsNeedsNewline(out.Bytes(), token[0]) {
+				out.WriteByte('\n')
+			} else if jsNeedsSpace(prev, token[0]) {
+				out.WriteByte(' ')
+			}
+		}
+		space = false
+		newline = false
+		out.Write(token)
+	}
+
+	for i := 0; i < len(data); {
+		c := data[i]
+		end := i + 1
+
+		switch {
+		case isSpace(c):
+			space = true
+			newline = newline || c == '\n'
+			i++
+			continue
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			end = bytes.IndexByte(data[i:], '\n')
+			if end < 0 {
+				end = len(data)
+			} else {
+				end += i
+			}
+			i = end
+			continue
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end = bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(data)
+			} else {
+				end += i + 4
+			}
+			if !bytes.HasPrefix(data[i:], []byte("/*!")) {
+				space = true
+				newline = newline || bytes.Contains(data[i:end], []byte("\n"))
+				i = end
+				continue
+			}
+		case c == '"' || c == '\'':
+			end = quotedEnd(data, i)
+		case c == '`':
+			end = jsTemplateEnd(data, i)
+		case c == '/' && jsRegexAllowed(out.Bytes()):
+			end = jsRegexEnd(data, i)
+		}
+
+		writeToken(data[i:end])
+		i = end
+	}
+	return out.Bytes()
+}
+
+// tagName returns the lowercased name of the tag starting at data[i], like
+// "p", or "/p" for a closing tag.
+func tagName(data []byte, i int) string {
+	j := i + 1
+	if j < len(data) && data[j] == '/' {
+		j++
+	}
+	for j < len(data) && !isSpace(data[j]) && data[j] != '>' && data[j] != '/' {
+		j++
+	}
+	return strings.ToLower(string(data[i+1 : j]))
+}
+
+// copyTag writes the tag starting at data[i] with the whitespace between
+// its attributes collapsed, and returns the index just past it. With
+// collapseValues whitespace in attribute values is collapsed too.
+func copyTag(out *bytes.Buffer, data []byte, i int, collapseValues bool) int {
+	space := false
+	for j := i; j < len(data); j++ {
+		c := data[j]
+		switch {
+		case c == '"' || c == '\'':
+			if space {
+				out.WriteByte(' ')
+				space = false
+			}
+			end := bytes.IndexByte(data[j+1:], c)
+			if end < 0 {
+				end = len(data)
+			} else {
+				end += j + 2
+			}
+			if collapseValues {
+				out.Write(bytes.Join(bytes.Fields(data[j:end]), []byte(" ")))
+			} else {
+				out.Write(data[j:end])
+			}
+			j = end - 1
+		case isSpace(c):
+			space = true
+		case c == '>':
+			out.WriteByte(c)
+			return j + 1
+		default:
+			if space && lastByte(out) != '=' && c != '=' {
+				out.WriteByte(' ')
+			}
+			space = false
+			out.WriteByte(c)
+		}
+	}
+	return len(data)
+}
+
+// rawTextEnd returns where the content of the element named name that
+// starts at data[i] ends, which is at its closing tag.
+func rawTextEnd(data []byte, i int, name string) int {
+	end := bytes.Index(bytes.ToLower(data[i:]), []byte("</"+name))
+	if end < 0 {
+		return len(data)
+	}
+	return i + end
+}
+
+func minifyHTML(data []byte) []byte {
+	var out bytes.Buffer
+	lastTag := ""
+
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case bytes.HasPrefix(data[i:], []byte("<!--")):
+			end := bytes.Index(data[i:], []byte("-->"))
+			if end < 0 {
+				end = len(data)
+			} else {
+				end += i + 3
+			}
+			if bytes.HasPrefix(data[i:], []byte("<!--[if")) {
+				out.Write(data[i:end])
+			}
+			i = end
+		case c == '<' && i+1 < len(data) && (data[i+1] == '/' || data[i+1] == '!' || (data[i+1] >= 'a' && data[i+1] <= 'z') || (data[i+1] >= 'A' && data[i+1] <= 'Z')):
+			name := tagName(data, i)
+			i = copyTag(&out, data, i, false)
+			lastTag = name
+
+			switch name {
+			case "pre", "textarea":
+				end := rawTextEnd(data, i, name)
+				out.Write(data[i:end])
+				i = end
+			case "script", "style":
+				end := rawTextEnd(data, i, name)
+				out.Write(minifyInlineCode(name, out.Bytes(), data[i:end]))
+				i = end
+			}
+		case isSpace(c):
+			end := i
+			for end < len(data) && isSpace(data[end]) {
+				end++
+			}
+			betweenTags := lastByte(&out) == '>' && end < len(data) && data[end] == '<'
+			if out.Len() > 0 && end < len(data) && lastByte(&out) != ' ' && !(betweenTags && (blockTags[strings.TrimPrefix(lastTag, "/")] || blockTags[strings.TrimPrefix(tagName(data, end), "/")])) {
+				out.WriteByte(' ')
+			}
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
+
+// minifyInlineCode minifies the content of a <script> or <style>, whose
+// opening tag ends html. Scripts that aren't JavaScript or JSON are left as
+// they are.
+func minifyInlineCode(name string, html []byte, code []byte) []byte {
+	if name == "style" {
+		return minifyCSS(code)
+	}
+
+	tag := strings.ToLower(string(html[bytes.LastIndex(html, []byte("<")):]))
+	switch {
+	case strings.Contains(tag, "json"):
+		var compacted bytes.Buffer
+		if json.Compact(&compacted, code) == nil {
+			return compacted.Bytes()
+		}
+		return code
+	case !strings.Contains(tag, "type=") || strings.Contains(tag, "javascript") || strings.Contains(tag, "module"):
+		return bytes.TrimSpace(minifyJS(code))
+	}
+	return code
+}
+
+func minifySVG(data []byte) []byte {
+	var out bytes.Buffer
+	textDepth := 0
+
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case bytes.HasPrefix(data[i:], []byte("<!--")):
+			end := bytes.Index(data[i:], []byte("-->"))
+			if end < 0 {
+				end = len(data)
+			} else {
+				end += i + 3
+			}
+			i = end
+		case bytes.HasPrefix(data[i:], []byte("<![CDATA[")):
+			end := bytes.Index(data[i:], []byte("]]>"))
+			if end < 0 {
+				end = len(data)
+			} else {
+				end += i + 3
+			}
+			out.Write(data[i:end])
+			i = end
+		case c == '<':
+			name := tagName(data, i)
+			start := out.Len()
+			i = copyTag(&out, data, i, true)
+			selfClosing := bytes.HasSuffix(out.Bytes()[start:], []byte("/>"))
+			if name == "text" && !selfClosing {
+				textDepth++
+			} else if name == "/text" && textDepth > 0 {
+				textDepth--
+			}
+		case isSpace(c):
+			end := i
+			for end < len(data) && isSpace(data[end]) {
+				end++
+			}
+			betweenTags := (out.Len() == 0 || lastByte(&out) == '>') && (end == len(data) || data[end] == '<')
+			if textDepth > 0 || !betweenTags {
+				out.WriteByte(' ')
+			}
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+type minifyTest struct {
+	name string
+	in   string
+	want string
+}
+
+func runMinifyTests(t *testing.T, minifier func([]byte) []byte, tests []minifyTest) {
+	t.Helper()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := string(minifier([]byte(test.in)))
+			if got != test.want {
+				t.Errorf("minified\n%s\ngot\n%s\nwant\n%s", test.in, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMinifyJS(t *testing.T) {
+	runMinifyTests(t, minifyJS, []minifyTest{
+		{"comments", "// line\nlet a = 1; /* block */ let b = 2;\n", "let a=1;let b=2;"},
+		{"postfix increment before a statement", "i++\nj--\nk()\n", "i++\nj--\nk()"},
+		{"prefix increment after a statement", "a\n++b\n", "a\n++b"},
+		{"plus plus across operators", "a + +b; c - -d; e + ++f;\n", "a+ +b;c- -d;e+ ++f;"},
+		{"return without a semicolon", "function f() {\n  return\n  1\n}\n", "function f(){return\n1}"},
+		{"regex after return", "function f(s) { return /a b\\/c/g.test(s) }\n", "function f(s){return/a b\\/c/g.test(s)}"},
+		{"regex after typeof and case", "switch (x) { case /x y/.source: break }\n", "switch(x){case/x y/.source:break}"},
+		{"regex with a class", "let r = /[/ ]+/g;\n", "let r=/[/ ]+/g;"},
+		{"division", "let half = a / 2 / b;\n", "let half=a/2/b;"},
+		{"template literal is kept as it is", "let s = `a  ${ b + `c  ${ d }` }  e`;\n", "let s=`a  ${ b + `c  ${ d }` }  e`;"},
+		{"template literal with a brace in a string", "let s = `${ \"}\" }  x`; let t = 1;\n", "let s=`${ \"}\" }  x`;let t=1;"},
+		{"strings keep their spaces", "let s = 'a  // b' + \"c  /* d */\";\n", "let s='a  // b'+\"c  /* d */\";"},
+		{"keywords keep their spaces", "const x = new Foo; if (a instanceof B) return typeof c;\n", "const x=new Foo;if(a instanceof B)return typeof c;"},
+	})
+}
+
+func TestMinifyCSS(t *testing.T) {
+	runMinifyTests(t, minifyCSS, []minifyTest{
+		{"comments and whitespace", "/* header */\nbody {\n  margin: 0;\n  color : red;\n}\n", "body{margin:0;color:red}"},
+		{"descendant pseudo-class", "nav :hover { color: red; }\n", "nav :hover{color:red}"},
+		{"pseudo-class on an element", "a:hover, a :focus-visible { color: red }\n", "a:hover,a :focus-visible{color:red}"},
+		{"nested rule in a media query", "@media (min-width: 600px) {\n  .a .b:first-child > p { margin: 0 auto; }\n}\n", "@media (min-width:600px){.a .b:first-child>p{margin:0 auto}}"},
+		{"strings keep their spaces", "a::after { content: \"a  ;  b\"; }\n", "a::after{content:\"a  ;  b\"}"},
+		{"calc keeps spaces around plus and minus", "div { width: calc(100% - 2 * 1em); }\n", "div{width:calc(100% - 2 * 1em)}"},
+	})
+}
+
+func TestMinifyHTML(t *testing.T) {
+	runMinifyTests(t, minifyHTML, []minifyTest{
+		{"whitespace between blocks", "<div>\n  <p>Hello   world</p>\n</div>\n", "<div><p>Hello world</p></div>"},
+		{"comments", "<p>a<!-- note --></p><!--[if IE]><p>ie</p><![endif]-->", "<p>a</p><!--[if IE]><p>ie</p><![endif]-->"},
+		{"pre", "<pre>\n  keep   this\n</pre>", "<pre>\n  keep   this\n</pre>"},
+		{"textarea", "<textarea>\n  keep   this\n</textarea>", "<textarea>\n  keep   this\n</textarea>"},
+		{"script", "<script>\n  let a = 1;\n</script>", "<script>let a=1;</script>"},
+		{"module script", "<script type=\"module\">\n  let a = 1;\n</script>", "<script type=\"module\">let a=1;</script>"},
+		{"json script", "<script type=\"application/json\">\n  { \"a\":  \"b  c\" }\n</script>", "<script type=\"application/json\">{\"a\":\"b  c\"}</script>"},
+		{"template script", "<script type=\"text/x-template\"><p>  {{ a }}  </p></script>", "<script type=\"text/x-template\"><p>  {{ a }}  </p></script>"},
+		{"style", "<style>\n  body { margin: 0; }\n</style>", "<style>body{margin:0}</style>"},
+	})
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A page lists a collection a few items at a time with front matter:
+//
+// ---
+// paginate: blog
+// perPage: 10
+// ---
+//
+// The page itself is page 1 and the rest are written to page/2/, page/3/,
+// etc. next to it, so src/pages/blog/index.html produces /blog/,
+// /blog/page/2/ and so on. Templates get the current slice of the
+// collection and the links between pages from .Paginator.
+
+const DEFAULT_PER_PAGE = 10
+
+type Paginator struct {
+	Collection string
+	Items      []Page
+	PageNumber int
+	PerPage    int
+	TotalPages int
+	TotalItems int
+	First      string
+	Last       string
+	Prev       string
+	Next       string
+}
+
+type paginatedPage struct {
+	Page     Page
+	DestPath string
+}
+
+func paginate(page Page, destPath string) []paginatedPage {
+	items := page.Site.Collections[page.FrontMatter.Paginate]
+
+	perPage := page.FrontMatter.PerPage
+	if perPage <= 0 {
+		perPage = DEFAULT_PER_PAGE
+	}
+
+	totalPages := (len(items) + perPage - 1) / perPage
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	base := paginationBase(page.Path)
+	pageURL := func(number int) string {
+		if number == 1 {
+			return page.Path
+		}
+		return fmt.Sprintf("%spage/%d/", base, number)
+	}
+
+	var pages []paginatedPage
+	for number := 1; number <= totalPages; number++ {
+		start := (number - 1) * perPage
+		end := start + perPage
+		if end > len(items) {
+			end = len(items)
+		}
+
+		paginator := &Paginator{
+			Collection: page.FrontMatter.Paginate,
+			Items:      items[start:end],
+			PageNumber: number,
+			PerPage:    perPage,
+			TotalPages: totalPages,
+			TotalItems: len(items),
+			First:      pageURL(1),
+			Last:       pageURL(totalPages),
+		}
+		if number > 1 {
+			paginator.Prev = pageURL(number - 1)
+		}
+		if number < totalPages {
+			paginator.Next = pageURL(number + 1)
+		}
+
+		numbered := page
+		numbered.Paginator = paginator
+		numberedDestPath := destPath
+		if number > 1 {
+			numbered.Path = pageURL(number)
+			numbered.URL = strings.TrimSuffix(site.BaseURL, "/") + numbered.Path
+			numberedDestPath = filepath.Join(config.Output, numbered.Path, "index.html")
+		}
+		pages = append(pages, paginatedPage{Page: numbered, DestPath: numberedDestPath})
+	}
+
+	return pages
+}
+
+// paginationBase is the URL that page/N/ is added to: /blog/ for /blog/ and
+// /archive/ for /archive.html.
+func paginationBase(path string) string {
+	if strings.HasSuffix(path, "/") {
+		return path
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "/"
+}
+
+// removeStalePagination deletes page/N/ directories left over from a build
+// when the collection had more pages.
+func removeStalePagination(page Page, totalPages int) {
+	for number := totalPages + 1; ; number++ {
+		dir := filepath.Join(config.Output, paginationBase(page.Path), "page", fmt.Sprint(number))
+		if _, err := os.Stat(dir); err != nil {
+			return
+		}
+		err := removeFromOutput(dir)
+		if err != nil {
+			fmt.Println("Error deleting:", dir, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// By default src/pages/about.md is built to dist/about.html. With
+// `prettyURLs: true` in the config it is built to dist/about/index.html and
+// served as /about/. 404.html stays where it is, since that is where hosts
+// look for the error page. A page can pick its own URL with front matter
+// `permalink: /company/about/`, and every page in a collection can follow a
+// pattern from the config:
+//
+// permalinks:
+//   blog: /blog/:year/:month/:slug/
+//
+// Patterns and permalinks can use :year, :month, :day (from the page's date),
+// :slug (front matter slug, or the file name), :title, :filename and
+// :section (the collection name). A page without a date can't use :year,
+// :month or :day and fails to build.
+
+const NOT_FOUND_PAGE = "404.html"
+
+var permalinkPlaceholderPattern = regexp.MustCompile(`:[a-z]+`)
+
+var permalinkPlaceholders = []string{":year", ":month", ":day", ":slug", ":title", ":filename", ":section"}
+
+// pageDestPath is where the page at srcPath is written in the output
+// directory.
+func pageDestPath(srcPath string, frontMatter FrontMatter) string {
+	language, srcPath := languageSourcePath(srcPath)
+	destPath := destPathFor(srcPath)
+
+	pattern := permalinkPattern(srcPath, frontMatter)
+	if pattern != "" {
+		return permalinkDestPath(localizePermalink(expandPermalink(pattern, srcPath, frontMatter), language))
+	}
+
+	if config.PrettyURLs && filepath.Base(destPath) != "index.html" && filepath.Base(destPath) != NOT_FOUND_PAGE {
+		return filepath.Join(strings.TrimSuffix(destPath, ".html"), "index.html")
+	}
+
+	return destPath
+}
+
+// permalinkPattern is the page's permalink, or the pattern of its collection.
+func permalinkPattern(srcPath string, frontMatter FrontMatter) string {
+	language, srcPath := languageSourcePath(srcPath)
+	if frontMatter.Permalink != "" || filepath.Base(destPathFor(srcPath)) == "index.html" {
+		return frontMatter.Permalink
+	}
+	return config.Permalinks[unlocalizedName(collectionName(srcPath), language)]
+}
+
+// checkPermalinkDate fails a page without a date whose permalink needs one,
+// rather than building it to /0001/01/01/.
+func checkPermalinkDate(srcPath string, frontMatter FrontMatter) error {
+	if !frontMatter.Date.IsZero() {
+		return nil
+	}
+	pattern := permalinkPattern(srcPath, frontMatter)
+	for _, placeholder := range []string{":year", ":month", ":day"} {
+		if strings.Contains(pattern, placeholder) {
+			return fmt.Errorf("permalink %q uses %s but the page has no date", pattern, placeholder)
+		}
+	}
+	return nil
+}
+
+func expandPermalink(pattern string, srcPath string, frontMatter FrontMatter) string {
+	filename := strings.TrimSuffix(filepath.Base(srcPath), filepath.Ext(srcPath))
+
+	slug, _ := frontMatter.Params["slug"].(string)
+	if slug == "" {
+		slug = filename
+	}
+
+	title := frontMatter.Title
+	if title == "" {
+		title = filename
+	}
+
+	return permalinkPlaceholderPattern.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		switch placeholder {
+		case ":year":
+			return fmt.Sprintf("%04d", frontMatter.Date.Year())
+		case ":month":
+			return fmt.Sprintf("%02d", int(frontMatter.Date.Month()))
+		case ":day":
+			return fmt.Sprintf("%02d", frontMatter.Date.Day())
+		case ":slug":
+			return slugify(slug)
+		case ":title":
+			return slugify(title)
+		case ":filename":
+			return filename
+		case ":section":
+			return collectionName(srcPath)
+		}
+		return placeholder
+	})
+}
+
+// permalinkDestPath turns a URL path into a file in the output directory.
+// URLs without a file extension are directories with an index.html.
+func permalinkDestPath(permalink string) string {
+	permalink = path.Clean("/" + permalink)
+	if path.Ext(permalink) == "" {
+		permalink = path.Join(permalink, "index.html")
+	}
+	return filepath.Join(config.Output, filepath.FromSlash(permalink))
+}
+
+// builtPagePath is where the page at srcPath was written by the last build,
+// which for a deleted page can't be worked out from its front matter.
+func builtPagePath(srcPath string) string {
+	for _, page := range site.Pages {
+		if page.SourcePath == srcPath {
+			return pageDestPath(srcPath, page.FrontMatter)
+		}
+	}
+	return pageDestPath(srcPath, FrontMatter{})
+}
+
+// removeOutput deletes a built page, and its directory too when that was
+// only there for a pretty URL.
+func removeOutput(destPath string) {
+	fmt.Println("Deleting from dist:", destPath)
+	_, err := os.Stat(destPath)
+	if err != nil {
+		return
+	}
+
+	err = os.RemoveAll(destPath)
+	if err != nil {
+		fmt.Println("Error deleting:", destPath, err)
+		return
+	}
+
+	if filepath.Base(destPath) == "index.html" {
+		// only removes the directory when it is empty
+		os.Remove(filepath.Dir(destPath))
+	}
+}
+
+func validatePermalink(pattern string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("%q must start with /", pattern)
+	}
+	for _, placeholder := range permalinkPlaceholderPattern.FindAllString(pattern, -1) {
+		if !sliceContains(placeholder, permalinkPlaceholders) {
+			return fmt.Errorf("%q has unknown placeholder %s", pattern, placeholder)
+		}
+	}
+	return nil
+}
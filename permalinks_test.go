@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDatePermalinkNeedsADate(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"src/layouts/Default.html":  "<html><body>__CONTENT__</body></html>",
+		"src/pages/blog/dated.md":   "---\ntitle: Dated\ndate: 2024-03-01\n---\nDated\n",
+		"src/pages/blog/undated.md": "---\ntitle: Undated\n---\nUndated\n",
+	})
+	config.Permalinks = map[string]string{"blog": "/blog/:year/:slug/"}
+
+	err := build(false)
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) || len(buildErr.Failures) != 1 || !strings.HasSuffix(buildErr.Failures[0].Path, "undated.md") {
+		t.Fatalf("got %v, want undated.md to fail", err)
+	}
+	if !strings.Contains(err.Error(), "has no date") {
+		t.Errorf("got %v, want it to say the page has no date", err)
+	}
+}
+
+func TestPrettyURLsKeepTheErrorPage(t *testing.T) {
+	config = defaultConfig()
+	config.PrettyURLs = true
+
+	for srcPath, want := range map[string]string{
+		filepath.Join("src", "pages", "about.md"):   filepath.Join("dist", "about", "index.html"),
+		filepath.Join("src", "pages", "404.html"):   filepath.Join("dist", "404.html"),
+		filepath.Join("src", "pages", "index.html"): filepath.Join("dist", "index.html"),
+	} {
+		if got := pageDestPath(srcPath, FrontMatter{}); got != want {
+			t.Errorf("%s is built to %s, want %s", srcPath, got, want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Pages can be kept out of the build with front matter:
+//
+// ---
+// draft: true
+// publishDate: 2025-01-01
+// expiryDate: 2025-06-01
+// ---
+//
+// sssg build skips drafts, pages whose publishDate is in the future and
+// pages whose expiryDate has passed. -drafts and -future build drafts and
+// future pages anyway. sssg dev builds everything and puts a banner on the
+// pages that sssg build would skip. Unpublished pages are never in feeds or
+// the sitemap.
+
+var includeDrafts bool
+var includeFuture bool
+var includeExpired bool
+var showStatusBanner bool
+
+var bodyTagPattern = regexp.MustCompile(`(?i)<body[^>]*>`)
+
+// pageStatus is "draft", "scheduled" or "expired" for pages that aren't
+// published, and "" for pages that are.
+func pageStatus(frontMatter FrontMatter) string {
+	now := time.Now()
+	switch {
+	case frontMatter.Draft:
+		return "draft"
+	case !frontMatter.PublishDate.IsZero() && frontMatter.PublishDate.After(now):
+		return "scheduled"
+	case !frontMatter.ExpiryDate.IsZero() && !frontMatter.ExpiryDate.After(now):
+		return "expired"
+	}
+	return ""
+}
+
+func isPublished(frontMatter FrontMatter) bool {
+	return pageStatus(frontMatter) == ""
+}
+
+// shouldBuild reports whether the page is built with the current flags.
+func shouldBuild(frontMatter FrontMatter) bool {
+	switch pageStatus(frontMatter) {
+	case "draft":
+		return includeDrafts
+	case "scheduled":
+		return includeFuture
+	case "expired":
+		return includeExpired
+	}
+	return true
+}
+
+func addStatusBanner(data []byte, frontMatter FrontMatter) []byte {
+	var message string
+	switch pageStatus(frontMatter) {
+	case "draft":
+		message = "Draft: sssg build will skip this page"
+	case "scheduled":
+		message = fmt.Sprintf("Scheduled: sssg build will skip this page until %s", frontMatter.PublishDate.Format("Jan 2, 2006 15:04"))
+	case "expired":
+		message = fmt.Sprintf("Expired: sssg build has skipped this page since %s", frontMatter.ExpiryDate.Format("Jan 2, 2006 15:04"))
+	default:
+		return data
+	}
+
+	banner := `<div class="sssg-status-banner" style="position:sticky;top:0;z-index:2147483647;padding:0.5rem 1rem;background:#fde68a;color:#78350f;font:bold 14px/1.4 sans-serif;text-align:center">` + message + `</div>`
+
+	location := bodyTagPattern.FindIndex(data)
+	if location == nil {
+		return append([]byte(banner), data...)
+	}
+
+	var withBanner []byte
+	withBanner = append(withBanner, data[:location[1]]...)
+	withBanner = append(withBanner, banner...)
+	withBanner = append(withBanner, data[location[1]:]...)
+	return withBanner
+}
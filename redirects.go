@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Old URLs can be sent to new ones in two ways. A page can list the URLs it
+// used to have:
+//
+// ---
+// aliases: [/old-path, /2019/01/old-post.html]
+// ---
+//
+// and each alias gets a stub page that redirects with a meta refresh. For
+// anything else, src/redirects has one redirect per line in the Netlify
+// format, with an optional status that defaults to 301:
+//
+// # from      to            status
+// /docs/*     /guide/:splat
+// /home       /             302
+// /news       /blog/        301!
+//
+// A status ending in ! is kept in _redirects, where it makes Netlify redirect
+// even when a file exists at the old path. sssg dev does the same, and
+// otherwise serves the file.
+//
+// All of them are written to dist/_redirects for Netlify and to
+// dist/redirects.nginx, which can be included in an nginx server block, and
+// sssg dev answers them with real redirects.
+
+const REDIRECTS_FILE = "redirects"
+
+type Redirect struct {
+	From   string
+	To     string
+	Status int
+	Force  bool
+	Alias  bool
+}
+
+// redirects is replaced by the file watcher while the dev server reads it,
+// so it is only used with redirectsMutex held.
+var redirects []Redirect
+var redirectsMutex sync.RWMutex
+
+func redirectsPath() string {
+	return filepath.Join(config.Source, REDIRECTS_FILE)
+}
+
+func initializeRedirects() error {
+	fmt.Println("Initializing redirects...")
+
+	loaded := []Redirect{}
+
+	file, err := os.Open(redirectsPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		lineNumber := 0
+		for scanner.Scan() {
+			lineNumber++
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			redirect, err := parseRedirect(line)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %w", redirectsPath(), lineNumber, err)
+			}
+			loaded = append(loaded, redirect)
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	for _, page := range site.Pages {
+		for _, alias := range page.FrontMatter.Aliases {
+			if !strings.HasPrefix(alias, "/") {
+				return fmt.Errorf("%s: alias %q must start with /", page.SourcePath, alias)
+			}
+			loaded = append(loaded, Redirect{From: alias, To: page.Path, Status: http.StatusMovedPermanently, Alias: true})
+		}
+	}
+
+	redirectsMutex.Lock()
+	redirects = loaded
+	redirectsMutex.Unlock()
+	return nil
+}
+
+func currentRedirects() []Redirect {
+	redirectsMutex.RLock()
+	defer redirectsMutex.RUnlock()
+	return redirects
+}
+
+func parseRedirect(line string) (Redirect, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return Redirect{}, fmt.Errorf("expected \"from to [status]\", got %q", line)
+	}
+
+	redirect := Redirect{From: fields[0], To: fields[1], Status: http.StatusMovedPermanently}
+	if !strings.HasPrefix(redirect.From, "/") {
+		return redirect, fmt.Errorf("%q must start with /", redirect.From)
+	}
+
+	if len(fields) == 3 {
+		status, err := strconv.Atoi(strings.TrimSuffix(fields[2], "!"))
+		if err != nil || (status != 301 && status != 302 && status != 303 && status != 307 && status != 308) {
+			return redirect, fmt.Errorf("status %q must be 301, 302, 303, 307 or 308", fields[2])
+		}
+		redirect.Status = status
+		redirect.Force = strings.HasSuffix(fields[2], "!")
+	}
+
+	return redirect, nil
+}
+
+// findRedirect returns the redirect for a request for urlPath, with its To
+// filled in for a splat.
+func findRedirect(urlPath string) (Redirect, bool) {
+	for _, redirect := range currentRedirects() {
+		if prefix, isSplat := strings.CutSuffix(redirect.From, "*"); isSplat {
+			if strings.HasPrefix(urlPath, prefix) {
+				redirect.To = replaceAWithB(redirect.To, ":splat", strings.TrimPrefix(urlPath, prefix))
+				return redirect, true
+			}
+			continue
+		}
+		if urlPath == redirect.From || strings.TrimSuffix(urlPath, "/") == strings.TrimSuffix(redirect.From, "/") {
+			return redirect, true
+		}
+	}
+	return Redirect{}, false
+}
+
+func buildRedirects() []PageError {
+	var netlify strings.Builder
+	var nginx strings.Builder
+	var failures []PageError
+
+	redirects := currentRedirects()
+	for _, redirect := range redirects {
+		if redirect.Alias {
+			failures = append(failures, failure(permalinkDestPath(redirect.From), buildAliasPage(redirect))...)
+		}
+
+		force := ""
+		if redirect.Force {
+			force = "!"
+		}
+		fmt.Fprintf(&netlify, "%s %s %d%s\n", redirect.From, redirect.To, redirect.Status, force)
+
+		if prefix, isSplat := strings.CutSuffix(redirect.From, "*"); isSplat {
+			to := replaceAWithB(redirect.To, ":splat", "$1")
+			fmt.Fprintf(&nginx, "location ~ ^%s(.*)$ { return %d %s; }\n", nginxRegexpEscape(prefix), redirect.Status, to)
+		} else {
+			fmt.Fprintf(&nginx, "location = %s { return %d %s; }\n", redirect.From, redirect.Status, redirect.To)
+		}
+	}
+
+	if len(redirects) == 0 {
+		return failures
+	}
+
+	netlifyPath := filepath.Join(config.Output, "_redirects")
+	nginxPath := filepath.Join(config.Output, "redirects.nginx")
+	failures = append(failures, failure(netlifyPath, writePage("(generated)", netlifyPath, []byte(netlify.String())))...)
+	return append(failures, failure(nginxPath, writePage("(generated)", nginxPath, []byte(nginx.String())))...)
+}
+
+func buildAliasPage(redirect Redirect) error {
+	to := html.EscapeString(siteURL(redirect.To))
+	stub := `<!DOCTYPE html>
+<html>
+<head>
+<title>Redirecting to ` + to + `</title>
+<link rel="canonical" href="` + to + `">
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="0; url=` + to + `">
+</head>
+<body>
+<a href="` + to + `">Redirecting to ` + to + `</a>
+</body>
+</html>
+`
+	return writePage("(generated)", permalinkDestPath(redirect.From), []byte(stub))
+}
+
+func nginxRegexpEscape(s string) string {
+	var escaped strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`\.+*?()|[]{}^$`, r) {
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
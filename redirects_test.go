@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestForcedRedirectsKeepTheirFlag(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"src/layouts/Default.html": "<html><body>__CONTENT__</body></html>",
+		"src/pages/index.html":     "<p>Home</p>",
+		"src/redirects":            "/home / 302\n/news /blog/ 301!\n",
+	})
+
+	err := build(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(config.Output, "_redirects"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "/home / 302\n/news /blog/ 301!\n"
+	if string(data) != want {
+		t.Errorf("got _redirects\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestDevServerRedirects(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"src/redirects":        "/old.html /new.html 301\n/kept.html /new.html 301!\n/gone /new.html 302\n",
+		"dist/old.html":        "<p>still here</p>",
+		"dist/kept.html":       "<p>shadowed</p>",
+		"dist/docs/index.html": "<p>docs</p>",
+	})
+	site = Site{}
+	err := initializeRedirects()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]int{
+		"/old.html":  http.StatusOK,
+		"/kept.html": http.StatusMovedPermanently,
+		"/gone":      http.StatusFound,
+	} {
+		recorder := httptest.NewRecorder()
+		requestHandler(recorder, httptest.NewRequest("GET", path, nil))
+		if recorder.Code != want {
+			t.Errorf("%s: got status %d, want %d", path, recorder.Code, want)
+		}
+	}
+}
+
+func TestRedirectsReloadWhileServing(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"src/redirects": "/home / 302\n",
+	})
+	site = Site{}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			initializeRedirects()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			findRedirect("/home")
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Markdown pages can use shortcodes, which are easier to write in markdown
+// than snippet tags:
+//
+// {{< youtube id="dQw4w9WgXcQ" >}}
+//
+// {{< callout type="warning" >}}
+// Back up **first**.
+// {{< /callout >}}
+//
+// A shortcode is a file in src/shortcodes, or otherwise a snippet, with the
+// same name. It is rendered like a snippet: attributes are template variables
+// and the content between the tags, rendered as markdown, is {{.Slot}}.
+// Shortcodes inside fenced code blocks are left alone.
+
+const SHORTCODE_PLACEHOLDER = "SSSGSHORTCODE%dX"
+
+var shortcodes []Snippet
+
+var shortcodeTagPattern = regexp.MustCompile(`\{\{<\s*(/?)\s*([A-Za-z][\w-]*)(.*?)(/?)\s*>\}\}`)
+
+type shortcodeTag struct {
+	start      int
+	end        int
+	name       string
+	attributes string
+	closing    bool
+	selfClosed bool
+}
+
+func initializeShortcodes() error {
+	fmt.Println("Initializing shortcodes...")
+
+	shortcodes = []Snippet{}
+
+	dir := filepath.Join(config.Source, "shortcodes")
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			base := filepath.Base(path)
+			shortcodes = append(shortcodes, Snippet{Name: strings.TrimSuffix(base, filepath.Ext(base)), Path: path})
+		}
+		return nil
+	})
+}
+
+// findShortcode looks in src/shortcodes first and then in src/snippets.
+func findShortcode(name string) (Snippet, bool) {
+	for _, list := range [][]Snippet{shortcodes, snippets} {
+		for _, shortcode := range list {
+			if strings.EqualFold(shortcode.Name, name) {
+				return shortcode, true
+			}
+		}
+	}
+	return Snippet{}, false
+}
+
+// shortcodeTags finds the shortcode tags in source that aren't in a fenced
+// code block.
+func shortcodeTags(source []byte) []shortcodeTag {
+	fences := codeFences(source)
+
+	var tags []shortcodeTag
+	for _, match := range shortcodeTagPattern.FindAllSubmatchIndex(source, -1) {
+		inFence := false
+		for _, fence := range fences {
+			if match[0] >= fence[0] && match[0] < fence[1] {
+				inFence = true
+				break
+			}
+		}
+		if inFence {
+			continue
+		}
+
+		tags = append(tags, shortcodeTag{
+			start:      match[0],
+			end:        match[1],
+			closing:    match[3] > match[2],
+			name:       string(source[match[4]:match[5]]),
+			attributes: string(source[match[6]:match[7]]),
+			selfClosed: match[9] > match[8],
+		})
+	}
+	return tags
+}
+
+// codeFences returns the start and end offsets of the fenced code blocks in
+// source. A block that is never closed runs to the end.
+func codeFences(source []byte) [][2]int {
+	var fences [][2]int
+	fence := ""
+	start := 0
+
+	offset := 0
+	for _, line := range strings.SplitAfter(string(source), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		marker := ""
+		if len(line)-len(trimmed) <= 3 && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")) {
+			marker = trimmed[:len(trimmed)-len(strings.TrimLeft(trimmed, trimmed[:1]))]
+		}
+
+		switch {
+		case fence == "" && marker != "":
+			fence = marker
+			start = offset
+		case fence != "" && marker != "" && marker[0] == fence[0] && len(marker) >= len(fence) && strings.TrimSpace(trimmed[len(marker):]) == "":
+			fences = append(fences, [2]int{start, offset + len(line)})
+			fence = ""
+		}
+		offset += len(line)
+	}
+
+	if fence != "" {
+		fences = append(fences, [2]int{start, len(source)})
+	}
+	return fences
+}
+
+// shortcodesUsedBy returns the files of the shortcodes source uses.
+func shortcodesUsedBy(source []byte) []Snippet {
+	var used []Snippet
+	for _, tag := range shortcodeTags(source) {
+		shortcode, found := findShortcode(tag.name)
+		if found && !tag.closing && !snippetFound(shortcode, used) {
+			used = append(used, shortcode)
+		}
+	}
+	return used
+}
+
+// renderMarkdownWithShortcodes swaps the shortcodes for placeholders, renders
+// the markdown and then puts the rendered shortcodes in their place.
+func renderMarkdownWithShortcodes(source []byte) ([]byte, error) {
+	tags := shortcodeTags(source)
+	if len(tags) == 0 {
+		return renderMarkdown(source)
+	}
+
+	var replaced bytes.Buffer
+	var rendered []string
+	last := 0
+
+	for i := 0; i < len(tags); i++ {
+		tag := tags[i]
+		if tag.closing {
+			return source, fmt.Errorf("shortcode {{< /%s >}} has no opening tag", tag.name)
+		}
+
+		shortcode, found := findShortcode(tag.name)
+		if !found {
+			return source, fmt.Errorf("unknown shortcode %q, add src/shortcodes/%s.html", tag.name, tag.name)
+		}
+
+		end := tag.end
+		inner := ""
+		if !tag.selfClosed {
+			if closer := matchingShortcodeTag(tags, i); closer > i {
+				innerHTML, err := renderShortcodeContent(source[tag.end:tags[closer].start])
+				if err != nil {
+					return source, err
+				}
+				inner = innerHTML
+				end = tags[closer].end
+				i = closer
+			}
+		}
+
+		html, err := renderSnippet(shortcode, tag.attributes, inner)
+		if err != nil {
+			return source, err
+		}
+
+		replaced.Write(source[last:tag.start])
+		fmt.Fprintf(&replaced, SHORTCODE_PLACEHOLDER, len(rendered))
+		rendered = append(rendered, html)
+		last = end
+	}
+	replaced.Write(source[last:])
+
+	output, err := renderMarkdown(replaced.Bytes())
+	if err != nil {
+		return source, err
+	}
+
+	for i, html := range rendered {
+		placeholder := []byte(fmt.Sprintf(SHORTCODE_PLACEHOLDER, i))
+		paragraph := append(append([]byte("<p>"), placeholder...), []byte("</p>")...)
+		if bytes.Contains(output, paragraph) {
+			output = bytes.Replace(output, paragraph, []byte(html), 1)
+		} else {
+			output = bytes.Replace(output, placeholder, []byte(html), 1)
+		}
+	}
+	return output, nil
+}
+
+// matchingShortcodeTag returns the index of the tag that closes tags[open],
+// or -1 when it has none and so stands alone.
+func matchingShortcodeTag(tags []shortcodeTag, open int) int {
+	depth := 0
+	for i := open + 1; i < len(tags); i++ {
+		if !strings.EqualFold(tags[i].name, tags[open].name) || tags[i].selfClosed {
+			continue
+		}
+		if !tags[i].closing {
+			depth++
+			continue
+		}
+		if depth == 0 {
+			return i
+		}
+		depth--
+	}
+	return -1
+}
+
+// renderShortcodeContent renders the content between a shortcode's tags as
+// markdown. Content on one line isn't wrapped in a paragraph.
+func renderShortcodeContent(source []byte) (string, error) {
+	rendered, err := renderMarkdownWithShortcodes(source)
+	if err != nil {
+		return "", err
+	}
+
+	html := strings.TrimSpace(string(rendered))
+	if !bytes.Contains(bytes.TrimSpace(source), []byte("\n")) {
+		if inner, found := strings.CutPrefix(html, "<p>"); found && strings.Count(html, "<p>") == 1 {
+			html = strings.TrimSuffix(inner, "</p>")
+		}
+	}
+	return html, nil
+}
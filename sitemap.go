@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// dist/sitemap.xml lists every built page except drafts and pages with
+// `sitemap: false` in their front matter. lastmod is the page's front matter
+// date, or else when its source file was last modified. A robots.txt
+// pointing at the sitemap is written too, unless src/pages has its own or
+// the config says `robots: false`.
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+func buildSitemap() []PageError {
+	if site.BaseURL == "" {
+		fmt.Println("Warning: baseURL is not set in the config so the links in the sitemap are not absolute")
+	}
+
+	var urlSet sitemapURLSet
+
+	for _, page := range site.Pages {
+		if !inSitemap(page) {
+			continue
+		}
+
+		lastMod := page.Date
+		if lastMod.IsZero() {
+			info, err := os.Stat(page.SourcePath)
+			if err == nil {
+				lastMod = info.ModTime()
+			}
+		}
+
+		urls := []string{page.URL}
+		if page.FrontMatter.Paginate != "" {
+			urls = nil
+			for _, paginated := range paginate(page, pageDestPath(page.SourcePath, page.FrontMatter)) {
+				urls = append(urls, paginated.Page.URL)
+			}
+		}
+
+		for _, url := range urls {
+			urlSet.URLs = append(urlSet.URLs, sitemapURL{Loc: url, LastMod: sitemapDate(lastMod)})
+		}
+	}
+
+	for _, taxonomy := range taxonomyNames {
+		terms := site.Taxonomies[taxonomy]
+		if len(terms) == 0 {
+			continue
+		}
+		urlSet.URLs = append(urlSet.URLs, sitemapURL{Loc: siteURL("/" + taxonomy + "/")})
+		for _, term := range terms {
+			urlSet.URLs = append(urlSet.URLs, sitemapURL{Loc: term.URL})
+		}
+	}
+
+	sitemapPath := filepath.Join(config.Output, "sitemap.xml")
+	failures := failure(sitemapPath, writeXML(sitemapPath, urlSet))
+	robotsPath := filepath.Join(config.Output, "robots.txt")
+	return append(failures, failure(robotsPath, buildRobots(robotsPath))...)
+}
+
+func inSitemap(page Page) bool {
+	if !isPublished(page.FrontMatter) {
+		return false
+	}
+	if page.FrontMatter.Sitemap != nil && !*page.FrontMatter.Sitemap {
+		return false
+	}
+	return true
+}
+
+func sitemapDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+func buildRobots(destPath string) error {
+	if !config.Robots {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(config.Source, "pages", "robots.txt")); err == nil {
+		return nil
+	}
+
+	robots := "User-agent: *\nAllow: /\n\nSitemap: " + siteURL("/sitemap.xml") + "\n"
+	return writePage("(generated)", destPath, []byte(robots))
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRobotsCanBeTurnedOff(t *testing.T) {
+	for settings, want := range map[string]bool{"": true, "robots: false\n": false} {
+		newTestSite(t, map[string]string{
+			"sssg.yaml":                settings,
+			"src/layouts/Default.html": "<html><body>__CONTENT__</body></html>",
+			"src/pages/index.html":     "<p>Home</p>",
+		})
+		var err error
+		config, err = loadConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = build(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = os.Stat(filepath.Join(config.Output, "robots.txt"))
+		if got := err == nil; got != want {
+			t.Errorf("with sssg.yaml %q robots.txt written is %v, want %v", settings, got, want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Snippets are used like HTML elements named after the snippet file:
+//
+// <Card title="Pricing" href="/pricing">Plans start at $5</Card>
+// <Card title="Pricing" />
+// <Card></Card>
+//
+// Attributes are available in the snippet as template variables ({{.title}},
+// {{.href}}) and the inner content as {{.Slot}} or __SLOT__. Attributes
+// without a value are set to true.
+//
+// Snippets can be used in pages, layouts and other snippets. A snippet that
+// ends up using itself, directly or through other snippets, fails the build.
+
+var snippetAttributePattern = regexp.MustCompile(`([^\s=/>]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+
+func snippetPattern(name string) *regexp.Regexp {
+	name = regexp.QuoteMeta(name)
+	return regexp.MustCompile(`(?s)<` + name + `((?:\s+[^<>]*?)?)\s*(?:/>|>(.*?)</` + name + `\s*>)`)
+}
+
+func parseSnippetAttributes(raw string) map[string]interface{} {
+	attributes := make(map[string]interface{})
+	for _, match := range snippetAttributePattern.FindAllStringSubmatch(raw, -1) {
+		name := match[1]
+		switch {
+		case strings.Contains(match[0], "="):
+			attributes[name] = match[2] + match[3] + match[4]
+		default:
+			attributes[name] = true
+		}
+	}
+	return attributes
+}
+
+func snippetUsed(content string, snippet Snippet) bool {
+	return snippet.Pattern.MatchString(content)
+}
+
+func renderSnippet(snippet Snippet, rawAttributes string, slot string) (string, error) {
+	snippetContent, err := os.ReadFile(snippet.Path)
+	if err != nil {
+		return "", err
+	}
+
+	data := parseSnippetAttributes(rawAttributes)
+	data["Slot"] = template.HTML(slot)
+	data["Site"] = site
+
+	text := replaceAWithB(string(snippetContent), "__SLOT__", "{{.Slot}}")
+	return renderTemplate(snippet.Path, text, data)
+}
+
+func processSnippets(data []byte) ([]byte, error) {
+	fmt.Println("Processing snippets...")
+	content, err := expandSnippets(string(data), nil)
+	return []byte(content), err
+}
+
+func expandSnippets(content string, chain []string) (string, error) {
+	var expandErr error
+	for _, snippet := range snippets {
+		content = snippet.Pattern.ReplaceAllStringFunc(content, func(match string) string {
+			if expandErr != nil {
+				return match
+			}
+			if sliceContains(snippet.Name, chain) {
+				expandErr = snippetCycleError(append(chain, snippet.Name))
+				return match
+			}
+			groups := snippet.Pattern.FindStringSubmatch(match)
+			rendered, err := renderSnippet(snippet, groups[1], groups[2])
+			if err != nil {
+				expandErr = err
+				return match
+			}
+			rendered, err = expandSnippets(rendered, append(chain[:len(chain):len(chain)], snippet.Name))
+			if err != nil {
+				expandErr = err
+			}
+			return rendered
+		})
+	}
+	return content, expandErr
+}
+
+func snippetCycleError(chain []string) error {
+	return fmt.Errorf("snippet cycle: %s", strings.Join(chain, " -> "))
+}
+
+// snippetsUsedBy returns every snippet content uses, including the snippets
+// those snippets use.
+func snippetsUsedBy(content string) ([]Snippet, error) {
+	var found []Snippet
+	err := collectSnippets(content, nil, &found)
+	return found, err
+}
+
+func collectSnippets(content string, chain []string, found *[]Snippet) error {
+	for _, snippet := range snippets {
+		if !snippetUsed(content, snippet) {
+			continue
+		}
+		if sliceContains(snippet.Name, chain) {
+			return snippetCycleError(append(chain, snippet.Name))
+		}
+		if snippetFound(snippet, *found) {
+			continue
+		}
+		*found = append(*found, snippet)
+
+		snippetContent, err := os.ReadFile(snippet.Path)
+		if err != nil {
+			return err
+		}
+		err = collectSnippets(string(snippetContent), append(chain[:len(chain):len(chain)], snippet.Name), found)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func snippetFound(snippet Snippet, found []Snippet) bool {
+	for _, f := range found {
+		if f.Path == snippet.Path {
+			return true
+		}
+	}
+	return false
+}
+
+func checkSnippetCycles() error {
+	for _, snippet := range snippets {
+		content, err := os.ReadFile(snippet.Path)
+		if err != nil {
+			return err
+		}
+		var found []Snippet
+		err = collectSnippets(string(content), []string{snippet.Name}, &found)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
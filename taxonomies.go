@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Pages are grouped by the tags and categories in their front matter:
+//
+// ---
+// tags: [go, ssg]
+// categories: [programming]
+// ---
+//
+// Each term gets a page listing its pages at /tags/go/ and each taxonomy an
+// overview at /tags/ with the number of pages per term. These are rendered
+// with the Term and Taxonomy layouts when they exist (the page gets .Term or
+// .Terms), and otherwise with a plain list in the default layout. A page in
+// src/pages at the same path wins over the generated one.
+//
+// Templates can list the terms anywhere, e.g. for a tag cloud:
+//
+// {{range .Site.Taxonomies.tags}}<a href="{{.Path}}">{{.Name}} ({{.Count}})</a>{{end}}
+
+const TAXONOMIES_DEPENDENCY = "taxonomies"
+
+var taxonomyNames = []string{"tags", "categories"}
+
+type Term struct {
+	Taxonomy string
+	Name     string
+	Slug     string
+	Path     string
+	URL      string
+	Count    int
+	Pages    []Page
+}
+
+const termPageBody = `<h1>{{.Title}}</h1>
+<ul>
+{{range .Term.Pages}}  <li><a href="{{.Path}}">{{.Title}}</a></li>
+{{end}}</ul>
+`
+
+const taxonomyPageBody = `<h1>{{.Title}}</h1>
+<ul>
+{{range .Terms}}  <li><a href="{{.Path}}">{{.Name}}</a> ({{.Count}})</li>
+{{end}}</ul>
+`
+
+func initializeTaxonomies() error {
+	fmt.Println("Initializing taxonomies...")
+
+	site.Taxonomies = make(map[string][]Term)
+
+	for _, taxonomy := range taxonomyNames {
+		terms := make(map[string]*Term)
+		for _, page := range site.Pages {
+			for _, name := range pageTermNames(page, taxonomy) {
+				slug := slugify(name)
+				if slug == "" {
+					continue
+				}
+				term, found := terms[slug]
+				if !found {
+					path := "/" + taxonomy + "/" + slug + "/"
+					term = &Term{
+						Taxonomy: taxonomy,
+						Name:     name,
+						Slug:     slug,
+						Path:     path,
+						URL:      strings.TrimSuffix(site.BaseURL, "/") + path,
+					}
+					terms[slug] = term
+				}
+				term.Pages = append(term.Pages, page)
+				term.Count++
+			}
+		}
+
+		if len(terms) == 0 {
+			continue
+		}
+
+		var sorted []Term
+		for _, term := range terms {
+			sorted = append(sorted, *term)
+		}
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i].Slug < sorted[j].Slug
+		})
+		site.Taxonomies[taxonomy] = sorted
+	}
+
+	return nil
+}
+
+func pageTermNames(page Page, taxonomy string) []string {
+	switch taxonomy {
+	case "tags":
+		return page.Tags
+	case "categories":
+		return page.Categories
+	}
+	return nil
+}
+
+// pageTerms returns the taxonomy/slug keys of every term the page at srcPath
+// is in, e.g. tags/go.
+func pageTerms(srcPath string) []string {
+	var keys []string
+	for _, page := range site.Pages {
+		if page.SourcePath != srcPath {
+			continue
+		}
+		for _, taxonomy := range taxonomyNames {
+			for _, name := range pageTermNames(page, taxonomy) {
+				keys = append(keys, taxonomy+"/"+slugify(name))
+			}
+		}
+	}
+	return keys
+}
+
+// buildTaxonomyPages writes the term and overview pages. When only is not nil
+// just the terms it names (as taxonomy/slug) and their overviews are written,
+// and any of them that no longer have pages are deleted.
+func buildTaxonomyPages(only []string) []PageError {
+	var failures []PageError
+	for _, taxonomy := range taxonomyNames {
+		terms := site.Taxonomies[taxonomy]
+		touched := only == nil
+
+		for _, term := range terms {
+			if only != nil && !sliceContains(taxonomy+"/"+term.Slug, only) {
+				continue
+			}
+			touched = true
+
+			page := newPage("", FrontMatter{Title: term.Name}, filepath.Join(config.Output, term.Path, "index.html"))
+			page.Term = &term
+			failures = append(failures, buildGeneratedPage(page, "Term", termPageBody)...)
+		}
+
+		for _, key := range only {
+			taxonomyName, slug, _ := strings.Cut(key, "/")
+			if taxonomyName != taxonomy || termExists(terms, slug) {
+				continue
+			}
+			touched = true
+			dir := filepath.Join(config.Output, taxonomy, slug)
+			fmt.Println("Deleting from dist:", dir)
+			failures = append(failures, failure(dir, os.RemoveAll(dir))...)
+		}
+
+		if touched && len(terms) > 0 {
+			page := newPage("", FrontMatter{Title: capitalize(taxonomy)}, filepath.Join(config.Output, taxonomy, "index.html"))
+			page.Terms = terms
+			failures = append(failures, buildGeneratedPage(page, "Taxonomy", taxonomyPageBody)...)
+		}
+	}
+	return failures
+}
+
+func termExists(terms []Term, slug string) bool {
+	for _, term := range terms {
+		if term.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// buildGeneratedPage renders a page that has no source file, unless a page
+// in src/pages already builds to the same path.
+func buildGeneratedPage(page Page, layoutName string, body string) []PageError {
+	destPath := filepath.Join(config.Output, page.Path, "index.html")
+	for _, existing := range site.Pages {
+		if pageDestPath(existing.SourcePath, existing.FrontMatter) == destPath {
+			return nil
+		}
+	}
+
+	if _, found := findLayout(layoutName); found {
+		page.FrontMatter.Layout = layoutName
+	}
+
+	// the body is a template like the body of an html page with front matter
+	template := true
+	page.FrontMatter.Template = &template
+
+	rendered, err := renderPage(destPath, []byte(body), page)
+	return failure(destPath, errors.Join(err, writePage("(generated)", destPath, rendered)))
+}
+
+func capitalize(s string) string {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return s
+	}
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTermPages(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"src/layouts/Default.html": "<html><body>__CONTENT__</body></html>",
+		"src/pages/blog/first.md":  "---\ntitle: First\ndate: 2024-01-01\ntags: [go]\n---\nFirst post\n",
+		"src/pages/blog/second.md": "---\ntitle: Second\ndate: 2024-02-01\ntags: [go, ssg]\n---\nSecond post\n",
+	})
+
+	err := build(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(config.Output, "tags", "go", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	term := string(data)
+	if strings.Contains(term, "{{") {
+		t.Errorf("/tags/go/ wasn't rendered as a template:\n%s", term)
+	}
+	for _, want := range []string{">go<", `href="/blog/first.html"`, `href="/blog/second.html"`} {
+		if !strings.Contains(term, want) {
+			t.Errorf("/tags/go/ doesn't contain %s:\n%s", want, term)
+		}
+	}
+
+	data, err = os.ReadFile(filepath.Join(config.Output, "tags", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overview := string(data); strings.Contains(overview, "{{") || !strings.Contains(overview, `href="/tags/ssg/"`) {
+		t.Errorf("/tags/ doesn't list the terms:\n%s", overview)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Layouts are rendered with html/template. The page being wrapped is the
+// template's dot, so a layout can use {{.Title}}, {{.Site.Title}},
+// {{.Path}}, {{.BuildTime}} and so on. __CONTENT__ is still supported and
+// is equivalent to {{.Content}}.
+//
+// html/template drops HTML comments, so they are set aside before parsing and
+// put back afterwards. That keeps license headers, SSI directives and IE
+// conditional comments, but template actions inside a comment aren't run.
+
+const COMMENT_PLACEHOLDER = "SSSGCOMMENT%dX"
+
+var htmlCommentPattern = regexp.MustCompile(`(?s)<!--.*?-->`)
+
+var templateFuncs = template.FuncMap{
+	"dateFormat": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"safeHTML": func(s string) template.HTML {
+		return template.HTML(s)
+	},
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+func newPage(srcPath string, frontMatter FrontMatter, destPath string) Page {
+	page := Page{
+		SourcePath:  srcPath,
+		Title:       frontMatter.Title,
+		Description: frontMatter.Description,
+		Date:        frontMatter.Date,
+		Tags:        frontMatter.Tags,
+		Categories:  frontMatter.Categories,
+		Params:      frontMatter.Params,
+		FrontMatter: frontMatter,
+		Path:        urlPathFor(destPath),
+		URL:         strings.TrimSuffix(site.BaseURL, "/") + urlPathFor(destPath),
+		Site:        site,
+		BuildTime:   buildTime,
+	}
+	addTranslations(&page)
+	return page
+}
+
+// urlPathFor turns dist/blog/index.html into /blog/ and dist/about.html
+// into /about.html.
+func urlPathFor(destPath string) string {
+	path := filepath.ToSlash(strings.TrimPrefix(destPath, config.Output))
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if strings.HasSuffix(path, "/index.html") {
+		path = strings.TrimSuffix(path, "index.html")
+	}
+	return path
+}
+
+func renderTemplate(name string, text string, data interface{}) (string, error) {
+	text = replaceAWithB(text, "__CONTENT__", "{{.Content}}")
+
+	var comments []string
+	text = htmlCommentPattern.ReplaceAllStringFunc(text, func(comment string) string {
+		comments = append(comments, comment)
+		return fmt.Sprintf(COMMENT_PLACEHOLDER, len(comments)-1)
+	})
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var rendered bytes.Buffer
+	err = tmpl.Execute(&rendered, data)
+	if err != nil {
+		return "", err
+	}
+
+	output := rendered.String()
+	for i, comment := range comments {
+		output = strings.Replace(output, fmt.Sprintf(COMMENT_PLACEHOLDER, i), comment, 1)
+	}
+	return output, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// Every page gets a table of contents built from the h2, h3 and h4 headings
+// of its content. Layouts can use the ready-made list:
+//
+// {{.TableOfContents}}
+//
+// or build their own from .TOC, where each entry has Level, ID, Title and
+// Children:
+//
+// {{range .TOC}}<a href="#{{.ID}}">{{.Title}}</a>{{end}}
+//
+// Headings without an id get one made from their text. Front matter
+// `tocDepth: 3` stops at h3 and `toc: false` leaves the page without one.
+
+const DEFAULT_TOC_DEPTH = 4
+
+type TOCEntry struct {
+	Level    int
+	ID       string
+	Title    string
+	Children []*TOCEntry
+}
+
+var headingPattern = regexp.MustCompile(`(?is)<h([2-6])(\s[^>]*)?>(.*?)</h[2-6]>`)
+var idAttributePattern = regexp.MustCompile(`(?i)\bid\s*=\s*"([^"]*)"`)
+var anchorLinkPattern = regexp.MustCompile(`(?is)<a class="anchor"[^>]*>.*?</a>`)
+
+// tableOfContents returns the headings of content as a tree, and content
+// with an id on every heading in the tree.
+func tableOfContents(content []byte, frontMatter FrontMatter) ([]byte, []*TOCEntry, error) {
+	if frontMatter.TOC != nil && !*frontMatter.TOC {
+		return content, nil, nil
+	}
+	depth := frontMatter.TOCDepth
+	if depth == 0 {
+		depth = DEFAULT_TOC_DEPTH
+	}
+	if depth < 2 || depth > 6 {
+		return content, nil, fmt.Errorf("tocDepth: %d is not between 2 and 6", depth)
+	}
+
+	usedIDs := make(map[string]bool)
+	for _, match := range idAttributePattern.FindAllSubmatch(content, -1) {
+		usedIDs[string(match[1])] = true
+	}
+
+	var entries []*TOCEntry
+	var parents []*TOCEntry
+
+	content = headingPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		groups := headingPattern.FindSubmatch(match)
+		level := int(groups[1][0] - '0')
+		if level > depth {
+			return match
+		}
+
+		attributes := string(groups[2])
+		inner := string(groups[3])
+		title := html.UnescapeString(strings.Join(strings.Fields(tagPattern.ReplaceAllString(anchorLinkPattern.ReplaceAllString(inner, ""), "")), " "))
+
+		id := ""
+		if idMatch := idAttributePattern.FindStringSubmatch(attributes); idMatch != nil {
+			id = idMatch[1]
+		} else {
+			id = uniqueID(slugify(title), usedIDs)
+			match = []byte(fmt.Sprintf(`<h%d id="%s"%s>%s</h%d>`, level, id, attributes, inner, level))
+		}
+
+		entry := &TOCEntry{Level: level, ID: id, Title: title}
+		for len(parents) > 0 && parents[len(parents)-1].Level >= level {
+			parents = parents[:len(parents)-1]
+		}
+		if len(parents) == 0 {
+			entries = append(entries, entry)
+		} else {
+			parent := parents[len(parents)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		parents = append(parents, entry)
+
+		return match
+	})
+
+	return content, entries, nil
+}
+
+func uniqueID(id string, usedIDs map[string]bool) string {
+	if id == "" {
+		id = "section"
+	}
+	unique := id
+	for i := 1; usedIDs[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", id, i)
+	}
+	usedIDs[unique] = true
+	return unique
+}
+
+// tocHTML renders entries as nested lists in a <nav class="toc">.
+func tocHTML(entries []*TOCEntry) template.HTML {
+	if len(entries) == 0 {
+		return ""
+	}
+
+	var list strings.Builder
+	list.WriteString("<nav class=\"toc\">\n")
+	writeTOCList(&list, entries)
+	list.WriteString("</nav>\n")
+	return template.HTML(list.String())
+}
+
+func writeTOCList(list *strings.Builder, entries []*TOCEntry) {
+	list.WriteString("<ul>\n")
+	for _, entry := range entries {
+		fmt.Fprintf(list, "<li><a href=\"#%s\">%s</a>", html.EscapeString(entry.ID), html.EscapeString(entry.Title))
+		if len(entry.Children) > 0 {
+			list.WriteString("\n")
+			writeTOCList(list, entry.Children)
+		}
+		list.WriteString("</li>\n")
+	}
+	list.WriteString("</ul>\n")
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"fmt"
+	"path/filepath"
 	"strings"
+	"unicode"
 )
 
 func sliceContains(str string, arr []string) bool {
@@ -16,3 +19,37 @@ func sliceContains(str string, arr []string) bool {
 func replaceAWithB(haystack string, A string, B string) string {
 	return strings.Replace(haystack, A, B, -1)
 }
+
+// inSrcDir reports whether path is the directory dir under the source
+// directory, or is inside it.
+func inSrcDir(path string, dir string) bool {
+	dir = filepath.Join(config.Source, dir)
+	path = filepath.Clean(path)
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// slugify turns "Go & SSGs" into "go-ssgs".
+func slugify(s string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteRune('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return slug.String()
+}
+
+// plural formats a count like "1 file" or "3 files".
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Pages are rendered by a pool of workers, as many as GOMAXPROCS unless
+// sssg.yaml sets `workers: 4`. See failures.go for what happens when a page
+// fails.
+
+func workerCount() int {
+	if config.Workers > 0 {
+		return config.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// renderPages builds every one of srcPaths concurrently, even after one
+// fails, and returns how many were built, how many were skipped as drafts or
+// scheduled pages, and the failures sorted by path.
+func renderPages(srcPaths []string) (int, int, []PageError) {
+	jobs := make(chan string)
+	rendered := 0
+	skipped := 0
+	var failures []PageError
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < workerCount(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for srcPath := range jobs {
+				built, err := buildPage(srcPath)
+				mutex.Lock()
+				if err != nil {
+					failures = append(failures, PageError{Path: srcPath, Err: err})
+				} else if built {
+					rendered++
+				} else {
+					skipped++
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, srcPath := range srcPaths {
+		jobs <- srcPath
+	}
+	close(jobs)
+	wg.Wait()
+
+	sortFailures(failures)
+	return rendered, skipped, failures
+}
+
+func printBuildSummary(rendered int, skipped int, unchanged int) {
+	fmt.Printf("Rendered %s with %s, %d unchanged, %d skipped\n", plural(rendered, "file"), plural(workerCount(), "worker"), unchanged, skipped)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const BENCHMARK_PAGES = 5000
+
+// BenchmarkBuild does a full build of a site with BENCHMARK_PAGES posts,
+// once with a single worker and once with the default number.
+func BenchmarkBuild(b *testing.B) {
+	files := map[string]string{
+		"src/layouts/Default.html": "<html><head><title>{{.Title}}</title></head><body><Nav></Nav>__CONTENT__</body></html>",
+		"src/snippets/Nav.html":    "<nav><a href=\"/\">Home</a> <a href=\"/blog/\">Blog</a></nav>",
+		"src/pages/index.html":     "<p>Home</p>",
+	}
+	for i := 0; i < BENCHMARK_PAGES; i++ {
+		files[fmt.Sprintf("src/pages/blog/post-%d.md", i)] = fmt.Sprintf("---\ntitle: Post %d\ndate: 2024-01-01\ntags: [go, ssg]\n---\n# Post %d\n\nSome *markdown* with a [link](/blog/) and code:\n\n```go\nfmt.Println(%d)\n```\n", i, i, i)
+	}
+
+	for _, workers := range []int{1, 0} {
+		name := fmt.Sprintf("workers=%d", workers)
+		if workers == 0 {
+			name = "workers=default"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			newTestSite(b, files)
+			config.Workers = workers
+			useBuildCache = false
+			defer func() { useBuildCache = true }()
+
+			// the build prints every page it writes
+			devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			stdout := os.Stdout
+			os.Stdout = devNull
+			defer func() {
+				os.Stdout = stdout
+				devNull.Close()
+			}()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := build(false)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			if _, err := os.Stat(filepath.Join(config.Output, "blog", "post-0.html")); err != nil {
+				b.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRenderPagesCountsSkippedPages(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"src/layouts/Default.html": "<html><body>__CONTENT__</body></html>",
+		"src/pages/about.md":       "---\ntitle: About\n---\nAbout\n",
+		"src/pages/draft.md":       "---\ntitle: Draft\ndraft: true\n---\nNot yet\n",
+	})
+	err := initializeLayouts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	includeDrafts = false
+
+	rendered, skipped, failures := renderPages([]string{
+		filepath.Join("src", "pages", "about.md"),
+		filepath.Join("src", "pages", "draft.md"),
+	})
+	if rendered != 1 || skipped != 1 || len(failures) > 0 {
+		t.Errorf("got %d rendered, %d skipped and failures %v, want 1 rendered and 1 skipped", rendered, skipped, failures)
+	}
+}